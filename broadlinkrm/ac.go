@@ -0,0 +1,115 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ACState is the state an air conditioner remote sends with every code
+type ACState struct {
+	Power bool `json:"power" yaml:"power"`
+	// Mode is one of auto, cool, heat, dry and fan
+	Mode string `json:"mode" yaml:"mode"`
+	// Temperature is the setpoint in °C
+	Temperature int `json:"temperature" yaml:"temperature"`
+	// Fan is one of auto, low, medium and high, some models know quiet or max as well
+	Fan   string `json:"fan" yaml:"fan"`
+	Swing bool   `json:"swing" yaml:"swing"`
+}
+
+// acModel encodes the states of the remotes of an air conditioner model
+type acModel struct {
+	names          []string
+	minTemperature int
+	maxTemperature int
+	modes          map[string]uint32
+	fans           map[string]uint32
+	// encode returns the pulses of a state with mode and fan translated by modes and fans
+	encode func(state ACState, mode uint32, fan uint32) []int
+}
+
+// acModels are the known air conditioner models
+var acModels = []acModel{
+	daikinModel,
+	mitsubishiHeavyModel,
+	greeModel,
+	lgModel,
+	fujitsuModel,
+}
+
+// ACModels returns the names of the known air conditioner models
+func ACModels() (names []string) {
+	for _, model := range acModels {
+		names = append(names, model.names[0])
+	}
+
+	return names
+}
+
+// EncodeAC encodes the state of an air conditioner into an IR packet in Broadlink format.
+// The model is one of ACModels, ignoring case.
+func EncodeAC(model string, state ACState) ([]byte, error) {
+	var ac *acModel
+	for i := range acModels {
+		for _, name := range acModels[i].names {
+			if strings.EqualFold(name, model) {
+				ac = &acModels[i]
+			}
+		}
+	}
+
+	if ac == nil {
+		return nil, fmt.Errorf("unknown air conditioner model %q", model)
+	}
+
+	mode, err := acSetting("mode", state.Mode, ac.modes)
+	if err != nil {
+		return nil, err
+	}
+
+	fan, err := acSetting("fan", state.Fan, ac.fans)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Temperature < ac.minTemperature || state.Temperature > ac.maxTemperature {
+		return nil, fmt.Errorf("temperature %d°C out of range %d-%d°C", state.Temperature, ac.minTemperature, ac.maxTemperature)
+	}
+
+	return lirc2broadlink(ac.encode(state, mode, fan))
+}
+
+// acSetting returns the value of a mode or fan setting
+func acSetting(kind string, setting string, values map[string]uint32) (uint32, error) {
+	if value, found := values[strings.ToLower(setting)]; found {
+		return value, nil
+	}
+
+	var known []string
+	for name := range values {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	return 0, fmt.Errorf("unsupported %v %q - supported are %v", kind, setting, strings.Join(known, ", "))
+}
+
+// spaceBytes appends the bytes with their bits LSB first, each bit is a pulse followed by the space of a one or a zero
+func (train *pulseTrain) spaceBytes(data []byte, pulse int, one int, zero int) {
+	for _, b := range data {
+		train.spaceBits(uint64(b), 8, pulse, one, zero)
+	}
+}
+
+// sumBytes returns the sum of the bytes modulo 256
+func sumBytes(data []byte) (sum byte) {
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
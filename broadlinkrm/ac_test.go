@@ -0,0 +1,70 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// readSpaceBytes reads count bytes written by spaceBytes
+func readSpaceBytes(t *testing.T, pulses []int, count int, pulse int, one int, zero int) []byte {
+	t.Helper()
+	data := make([]byte, count)
+	for i := range data {
+		value, ok := readSpaceBits(pulses[16*i:], 8, pulse, one, zero)
+		if !ok {
+			t.Fatalf("byte %d is not encoded", i)
+		}
+		data[i] = byte(value)
+	}
+
+	return data
+}
+
+func TestEncodeAC(t *testing.T) {
+	if models := ACModels(); !reflect.DeepEqual(models, []string{"daikin", "mitsubishi-heavy", "gree", "lg", "fujitsu"}) {
+		t.Errorf("got models %v", models)
+	}
+
+	// every model encodes a packet with the pulses of its encoder, model and settings ignore case
+	for _, model := range ACModels() {
+		packet, err := EncodeAC(model, ACState{Power: true, Mode: "COOL", Temperature: 22, Fan: "Auto"})
+		if err != nil {
+			t.Errorf("%v: %v", model, err)
+			continue
+		}
+
+		if pulses, err := irPulses(packet); err != nil || len(pulses) < 20 {
+			t.Errorf("%v: got packet %x (%v)", model, packet, err)
+		}
+	}
+
+	tests := map[string]ACState{
+		"toshiba": {Power: true, Mode: "cool", Temperature: 24, Fan: "auto"},
+		"daikin":  {Power: true, Mode: "turbo", Temperature: 24, Fan: "auto"},
+		"gree":    {Power: true, Mode: "cool", Temperature: 24, Fan: "quiet"},
+		"lg":      {Power: true, Mode: "cool", Temperature: 31, Fan: "auto"},
+	}
+	for model, state := range tests {
+		if _, err := EncodeAC(model, state); err == nil {
+			t.Errorf("%v: no error for %+v", model, state)
+		}
+	}
+}
+
+func TestSpaceBytes(t *testing.T) {
+	var train pulseTrain
+	train.spaceBytes([]byte{0x11, 0xda}, 400, 1200, 400)
+	if data := readSpaceBytes(t, train, 2, 400, 1200, 400); !bytes.Equal(data, []byte{0x11, 0xda}) {
+		t.Errorf("got %x", data)
+	}
+}
+
+func TestSumBytes(t *testing.T) {
+	if sum := sumBytes([]byte{0x11, 0xda, 0x27, 0x00, 0xc5, 0x00, 0x00}); sum != 0xd7 {
+		t.Errorf("got 0x%02x, want 0xd7", sum)
+	}
+}
@@ -15,10 +15,13 @@ import (
 	"crypto/cipher"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"log"
 	"math"
 	"net"
 	"os"
+	"reflect"
+	"sync"
 	"time"
 )
 
@@ -27,6 +30,7 @@ type Device struct {
 	DeviceType uint16
 	DeviceName string
 	DeviceAddr *net.UDPAddr
+	Locked     bool
 	deviceMac  [6]byte
 	deviceID   uint32
 	deviceKey  []byte
@@ -37,30 +41,140 @@ func (dev Device) DeviceMac() []byte {
 	return reverseArray(dev.deviceMac[:])
 }
 
+// Model gets the model name of the device, empty if the device type is unknown
+func (dev Device) Model() string {
+	return deviceModels[dev.DeviceType]
+}
+
+// Exchange describes one request/response exchange with a device
+type Exchange struct {
+	Device Device
+	// Packet type of the request, 0x65 for Auth and 0x6a for Command
+	Packet uint16
+	// Command send with Command, 0 for Auth
+	Command uint32
+	// Duration from sending the request until the response or the timeout
+	Duration time.Duration
+	// Timeout is set if the device did not answer
+	Timeout bool
+	// ErrorCode returned by the device, 0 on success
+	ErrorCode int16
+}
+
+// PlugState holds the state read from a smart plug
+type PlugState struct {
+	On       bool
+	Power    float64
+	HasPower bool
+}
+
+// Sensors holds the values read from the sensors of an device
+type Sensors struct {
+	Temperature float64
+	Humidity    float64
+	HasHumidity bool
+}
+
 const (
 	broadcast = "255.255.255.255:80"
 )
 
+var (
+	// ErrNoResponse is returned if a device did not answer within the timeout
+	ErrNoResponse = errors.New("no response from device")
+	// ErrCommandFailed is returned if a device did not answer a command or returned an error
+	ErrCommandFailed = errors.New("command failed")
+)
+
+// deviceModels maps the known device types to their model names
+var deviceModels = map[uint16]string{
+	0x0000: "SP1",
+	0x2711: "SP2",
+	0x2714: "A1",
+	0x2728: "SP mini 2",
+	0x7530: "SP mini 2",
+	0x753e: "SP mini 3",
+	0x9479: "SP3S-US",
+	0x947a: "SP3S-EU",
+	0x2712: "RM pro/pro+",
+	0x272a: "RM pro",
+	0x2737: "RM mini 3",
+	0x273d: "RM pro",
+	0x277c: "RM home",
+	0x2783: "RM home",
+	0x2787: "RM pro",
+	0x278b: "RM plus",
+	0x278f: "RM mini",
+	0x2797: "RM pro+",
+	0x279d: "RM pro+",
+	0x27a1: "RM plus",
+	0x27a6: "RM plus",
+	0x27a9: "RM pro+",
+	0x27c2: "RM mini 3",
+	0x27c3: "RM pro+",
+	0x27cc: "RM mini 3",
+	0x27cd: "RM mini 3",
+	0x27d0: "RM mini 3",
+	0x27d1: "RM mini 3",
+	0x27d3: "RM mini 3",
+	0x27de: "RM mini 3",
+	0x5f36: "RM mini 3",
+	0x6507: "RM mini 3",
+	0x6508: "RM mini 3",
+	0x51da: "RM4 mini",
+	0x5209: "RM4 TV mate",
+	0x520c: "RM4 mini",
+	0x520d: "RM4C mini",
+	0x5211: "RM4C mate",
+	0x5212: "RM4 TV mate",
+	0x5216: "RM4 mini",
+	0x521c: "RM4 mini",
+	0x6026: "RM4 pro",
+	0x6070: "RM4C mini",
+	0x610e: "RM4 mini",
+	0x610f: "RM4C mini",
+	0x61a2: "RM4 pro",
+	0x62bc: "RM4 mini",
+	0x62be: "RM4C mini",
+	0x6364: "RM4S",
+	0x648d: "RM4 mini",
+	0x649b: "RM4 pro",
+	0x6539: "RM4C mini",
+	0x653a: "RM4 mini",
+	0x653c: "RM4 pro",
+}
+
 var (
 	// DefaultTimeout to use for waiting for response
 	DefaultTimeout = time.Duration(60)
 	// LogWarnings - set to true to see warnings in log
 	LogWarnings = false
+	// OnExchange is called after every request/response exchange with a device if set, e.g. to collect metrics
+	OnExchange func(Exchange)
 
 	defaultKey       = []byte{0x09, 0x76, 0x28, 0x34, 0x3f, 0xe9, 0x9e, 0x23, 0x76, 0x5c, 0x15, 0x13, 0xac, 0xcf, 0x8b, 0x02}
 	deviceIv         = []byte{0x56, 0x2e, 0x17, 0x99, 0x6d, 0x09, 0x3d, 0x28, 0xdd, 0xb3, 0xba, 0x69, 0x5a, 0x2e, 0x6f, 0x58}
 	sendCount        = uint16(0)
+	sendLock         sync.Mutex                      // guards sendCount
+	deviceLocks      = make(map[[6]byte]*sync.Mutex) // serialise the exchanges per device MAC
+	deviceLocksLock  sync.Mutex                      // guards deviceLocks
+	joinLock         sync.Mutex                      // serialises Join, devices in AP mode are not known by their MAC
+	waiters          = make(map[*waiter]bool)        // receive the responses of the devices
+	waitersLock      sync.Mutex                      // guards waiters
 	udpServer, _     = net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
-	responses        chan ([]byte)
 	updBroadcastAddr *net.UDPAddr
 )
 
+// waiter receives the responses of the expected type accepted by match, a nil match accepts all
+type waiter struct {
+	expectedType uint16
+	match        func([]byte) bool
+	responses    chan []byte
+}
+
 func init() {
-	if responses == nil {
-		updBroadcastAddr, _ = net.ResolveUDPAddr("udp4", broadcast)
-		responses = make(chan []byte, 1000)
-		go udpListener()
-	}
+	updBroadcastAddr, _ = net.ResolveUDPAddr("udp4", broadcast)
+	go udpListener()
 }
 
 // Hello - find broadlink devices on the local network and get base infos about the devices.
@@ -84,6 +198,8 @@ func Hello(timeout time.Duration, deviceIP net.IP) (devices chan Device) {
 	payload[0x26] = 0x06                                                                             // Command Hello
 	binary.LittleEndian.PutUint16(payload[0x20:], makeChecksum(payload))
 
+	// listen before sending, so no answer is missed
+	w := listen(0x07, nil)
 	if deviceIP == nil {
 		udpServer.WriteTo(payload, updBroadcastAddr)
 	} else {
@@ -92,29 +208,28 @@ func Hello(timeout time.Duration, deviceIP net.IP) (devices chan Device) {
 
 	devices = make(chan (Device), 100)
 
-	go asyncHelloResponse(timeout, devices)
+	go asyncHelloResponse(timeout, w, devices)
 
 	return
 }
 
-func asyncHelloResponse(timeout time.Duration, devices chan Device) {
+func asyncHelloResponse(timeout time.Duration, w *waiter, devices chan Device) {
 	defer close(devices)
+	defer w.stop()
 
-	startTime := time.Now().Add(timeout * time.Second)
-	waitTimeout := timeout
-
+	deadline := time.Now().Add(timeout * time.Second)
 	if timeout < 1 {
-		startTime = time.Now().Add(DefaultTimeout * time.Second)
-		waitTimeout = DefaultTimeout
+		deadline = time.Now().Add(DefaultTimeout * time.Second)
 	}
 
 	for {
-		buf := wait4Response(0x07, waitTimeout)
+		buf := w.wait(deadline)
 
-		if buf != nil {
+		if buf != nil && len(buf) >= 0x41 {
 			dev := Device{
 				DeviceType: binary.LittleEndian.Uint16(buf[0x34:]),
-				DeviceName: string(buf[0x40:]),
+				DeviceName: string(bytes.SplitN(buf[0x40:], []byte{0x00}, 2)[0]),
+				Locked:     buf[len(buf)-1] != 0,
 				deviceID:   0,
 				deviceKey:  make([]byte, len(defaultKey)),
 			}
@@ -123,7 +238,7 @@ func asyncHelloResponse(timeout time.Duration, devices chan Device) {
 			copy(dev.deviceKey, defaultKey)
 			dev.DeviceAddr = &net.UDPAddr{IP: net.IPv4(buf[0x39], buf[0x38], buf[0x37], buf[0x36]), Port: 80}
 			devices <- dev
-		} else if !time.Now().Before(startTime) {
+		} else if buf == nil {
 			break
 		}
 
@@ -134,6 +249,7 @@ func asyncHelloResponse(timeout time.Duration, devices chan Device) {
 }
 
 // Command - send a command with parameters to an device.
+// Command, Auth and Join may be called concurrently, the exchanges with the same device are serialised.
 //
 // cmd - command to send, knowen command's: 2 send, 3 learn, 4 fetch last learned code
 // data - parameters for command
@@ -155,12 +271,17 @@ func Command(cmd uint32, data []byte, dev *Device) []byte {
 		payload = append([]byte{0x04, 0x00}, payload...)
 	}
 
-	send(0x6a, dev, payload)
+	defer lockDevice(dev)()
 
-	response := wait4Response(0x3ee, DefaultTimeout)
-	if len(response) >= 0x22 {
+	start := time.Now()
+	response := exchange(0x6a, dev, payload, 0x3ee)
+	reportExchange(dev, 0x6a, cmd, start, response)
+	if len(response) >= 0x38 {
 		if int16(binary.LittleEndian.Uint16(response[0x22:])) == 0 {
-			decrypted, _ := decrypt(dev.deviceKey, deviceIv, response[0x38:])
+			decrypted, err := decrypt(dev.deviceKey, deviceIv, response[0x38:])
+			if err != nil || len(decrypted) < 4 {
+				return nil
+			}
 			return decrypted[4:]
 		}
 
@@ -195,13 +316,19 @@ func Join(ssid string, password string, securityMode byte, deviceIP net.IP) []by
 
 	binary.LittleEndian.PutUint16(payload[0x20:0x22], makeChecksum(payload))
 
+	joinLock.Lock()
+	defer joinLock.Unlock()
+
+	w := listen(0x15, nil)
+	defer w.stop()
+
 	if deviceIP == nil {
 		udpServer.WriteTo(payload, updBroadcastAddr)
 	} else {
 		udpServer.WriteTo(payload, &net.UDPAddr{IP: deviceIP, Port: 80})
 	}
 
-	response := wait4Response(0x15, DefaultTimeout)
+	response := w.wait(time.Now().Add(DefaultTimeout * time.Second))
 
 	// todo
 	// expected response 0000000000000000000000000000000000000000000000000000000000000000c4be0000000015000000000000000000
@@ -212,18 +339,100 @@ func Join(ssid string, password string, securityMode byte, deviceIP net.IP) []by
 // Auth against an device for further usage.
 //
 // dev - device structure returned from Hello where authentication is send to
-func Auth(dev *Device) {
+// Returned is ErrNoResponse if the device did not answer.
+func Auth(dev *Device) error {
 	payload := make([]byte, 0x50)
 	payload[0x2d] = 0x01
 
 	hostname, _ := os.Hostname()
 	copy(payload[0x30:], []byte(hostname))
 
-	send(0x65, dev, payload)
+	defer lockDevice(dev)()
+
+	start := time.Now()
+	response := exchange(0x65, dev, payload, 0x3e9)
+	reportExchange(dev, 0x65, 0, start, response)
+	if len(response) <= 0x38 {
+		return ErrNoResponse
+	}
+
+	decrypted, err := decrypt(dev.deviceKey, deviceIv, response[0x38:])
+	if err != nil {
+		return err
+	}
+
+	if len(decrypted) < 0x14 {
+		return errors.New("authentication response is to small")
+	}
 
-	decrypted, _ := decrypt(dev.deviceKey, deviceIv, wait4Response(0x3e9, DefaultTimeout)[0x38:])
 	dev.deviceID = binary.LittleEndian.Uint32(decrypted[0x00:])
 	dev.deviceKey = decrypted[0x04:0x14]
+	return nil
+}
+
+// ReadSensors reads the temperature and, if supported by the device, the humidity.
+//
+// dev - authenticated device structure returned from Hello
+// Returned is ErrNoResponse if the device did not answer or returned an error.
+func ReadSensors(dev *Device) (sensors Sensors, err error) {
+	if dev.DeviceType == 0x5f36 {
+		response := Command(0x24, nil, dev)
+		if len(response) < 6 {
+			return sensors, ErrNoResponse
+		}
+
+		sensors.Temperature = float64(response[2]) + float64(response[3])/100
+		sensors.Humidity = float64(response[4]) + float64(response[5])/100
+		sensors.HasHumidity = true
+		return
+	}
+
+	response := Command(1, nil, dev)
+	if len(response) < 2 {
+		return sensors, ErrNoResponse
+	}
+
+	sensors.Temperature = float64(response[0]) + float64(response[1])/10
+	return
+}
+
+// ReadPlug reads the power state and, if supported by the device, the current power consumption of a smart plug.
+//
+// dev - authenticated device structure returned from Hello
+// Returned is ErrNoResponse if the device did not answer or returned an error.
+func ReadPlug(dev *Device) (state PlugState, err error) {
+	response := Command(1, nil, dev)
+	if len(response) < 1 {
+		return state, ErrNoResponse
+	}
+
+	state.On = response[0]&0x01 != 0
+
+	// energy request, the power is returned bcd encoded in W
+	response = Command(0x01fe0008, []byte{0x05, 0x01, 0x00, 0x00, 0x00, 0x2d}, dev)
+	if len(response) >= 4 {
+		state.Power = float64(bcd(response[3])*100+bcd(response[2])) + float64(bcd(response[1]))/100
+		state.HasPower = true
+	}
+
+	return
+}
+
+func bcd(value byte) int {
+	return int(value>>4)*10 + int(value&0x0f)
+}
+
+func reportExchange(dev *Device, packet uint16, cmd uint32, start time.Time, response []byte) {
+	if OnExchange == nil {
+		return
+	}
+
+	exchange := Exchange{Device: *dev, Packet: packet, Command: cmd, Duration: time.Since(start), Timeout: response == nil}
+	if len(response) >= 0x24 {
+		exchange.ErrorCode = int16(binary.LittleEndian.Uint16(response[0x22:]))
+	}
+
+	OnExchange(exchange)
 }
 
 func makeChecksum(payload []byte) uint16 {
@@ -320,46 +529,117 @@ func udpListener() {
 	for {
 		buf := make([]byte, 2048)
 		count, _, err := udpServer.ReadFrom(buf)
-		if err != nil {
+		if err != nil || count < 0x30 {
 			continue
 		}
 
 		if checkChecksum(buf, 0x20) {
 			response := make([]byte, count)
 			copy(response, buf)
-			responses <- response
+			dispatch(response)
 		}
 	}
 }
 
-func wait4Response(expectedType uint16, timeout time.Duration) []byte {
-	startTime := time.Now().Add(timeout * time.Second)
-	for {
-		select {
-		case buf := <-responses:
-			msgType := binary.LittleEndian.Uint16(buf[0x26:0x28])
-			if msgType == expectedType {
-				return buf
-			}
-
-			responses <- buf
-			if !time.Now().Before(startTime) {
-				return nil
+// dispatch hands a response to the waiters accepting it.
+// Responses no waiter accepts are late answers to requests that timed out and are dropped.
+func dispatch(buf []byte) {
+	msgType := binary.LittleEndian.Uint16(buf[0x26:0x28])
+	delivered := false
+
+	waitersLock.Lock()
+	for w := range waiters {
+		if w.expectedType == msgType && (w.match == nil || w.match(buf)) {
+			select {
+			case w.responses <- buf:
+				delivered = true
+			default:
 			}
-		case <-time.After(timeout * time.Second):
-			return nil
 		}
 	}
+	waitersLock.Unlock()
+
+	if !delivered && LogWarnings {
+		log.Printf("late response of type 0x%x dropped\n", msgType)
+	}
 }
 
-func send(command uint16, dev *Device, payload []byte) {
+// listen registers a waiter for the responses of the expected type accepted by match, a nil match accepts all.
+// It is registered before the request is sent, so an early answer is not missed, and removed with stop.
+func listen(expectedType uint16, match func([]byte) bool) *waiter {
+	w := &waiter{expectedType: expectedType, match: match, responses: make(chan []byte, 100)}
+
+	waitersLock.Lock()
+	waiters[w] = true
+	waitersLock.Unlock()
+
+	return w
+}
+
+// stop removes the waiter, responses arriving later are dropped
+func (w *waiter) stop() {
+	waitersLock.Lock()
+	delete(waiters, w)
+	waitersLock.Unlock()
+}
+
+// wait returns the next response, nil if none arrived until the deadline
+func (w *waiter) wait(deadline time.Time) []byte {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case buf := <-w.responses:
+		return buf
+	case <-timer.C:
+		return nil
+	}
+}
+
+// lockDevice serialises the exchanges with a device and returns the function unlocking it.
+// Exchanges with other devices go on while a device does not answer.
+func lockDevice(dev *Device) (unlock func()) {
+	deviceLocksLock.Lock()
+	lock, found := deviceLocks[dev.deviceMac]
+	if !found {
+		lock = new(sync.Mutex)
+		deviceLocks[dev.deviceMac] = lock
+	}
+	deviceLocksLock.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// exchange sends a packet to the device and waits for its answer.
+// Answers are matched by the packet count and the MAC of the device, so a late answer to a request that timed out is not taken for the answer of the next one.
+func exchange(command uint16, dev *Device, payload []byte, expectedType uint16) []byte {
+	count := nextSendCount()
+	w := listen(expectedType, func(buf []byte) bool {
+		return binary.LittleEndian.Uint16(buf[0x28:]) == count && bytes.Equal(buf[0x2a:0x30], dev.deviceMac[:])
+	})
+	defer w.stop()
+
+	send(command, dev, payload, count)
+	return w.wait(time.Now().Add(DefaultTimeout * time.Second))
+}
+
+// nextSendCount returns the packet count of the next request
+func nextSendCount() uint16 {
+	sendLock.Lock()
+	defer sendLock.Unlock()
+
 	sendCount++
+	return sendCount
+}
 
+// send sends a packet with the given packet count to the device
+func send(command uint16, dev *Device, payload []byte, count uint16) {
 	buffer := make([]byte, 0x38)
 	copy(buffer[0:], []byte{0x5a, 0xa5, 0xaa, 0x55, 0x5a, 0xa5, 0xaa, 0x55, 0x00})
 	binary.LittleEndian.PutUint16(buffer[0x24:], dev.DeviceType)
 	binary.LittleEndian.PutUint16(buffer[0x26:], command)
-	binary.LittleEndian.PutUint16(buffer[0x28:], sendCount)
+	binary.LittleEndian.PutUint16(buffer[0x28:], count)
 	copy(buffer[0x2a:], dev.deviceMac[0:])
 	binary.LittleEndian.PutUint32(buffer[0x30:], dev.deviceID)
 	if (payload != nil) && (len(payload) > 0) {
@@ -376,109 +656,172 @@ func send(command uint16, dev *Device, payload []byte) {
 // *** Converter ***
 // Based on the code from https://community.home-assistant.io/t/configuration-of-broadlink-ir-device-and-getting-the-right-ir-codes/48391
 
-// ConvertPronto2Broadlink converts pronto codes to broadlink code
+// ConvertPronto2Broadlink converts pronto codes to broadlink code.
+// An invalid code is logged and nil is returned.
+//
+// Deprecated: use Pronto2Broadlink.
 func ConvertPronto2Broadlink(prontoByte []byte) []byte {
-	lircCode := pronto2lirc(prontoByte)
-	broadlinkCode := lirc2broadlink(lircCode)
+	broadlinkCode, err := Pronto2Broadlink(prontoByte)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
 
 	return broadlinkCode
 }
 
-// ConvertBroadlink2Pronto converts broadlink codes to pronto code
+// ConvertBroadlink2Pronto converts broadlink codes to pronto code.
+// An invalid code is logged and nil is returned.
+//
+// Deprecated: use Broadlink2Pronto.
 func ConvertBroadlink2Pronto(broadlinkByte []byte, prontoIrFrequency uint16) []byte {
-	lircCode := broadlink2lirc(broadlinkByte)
-	prontoByte := lirc2pronto(lircCode, prontoIrFrequency)
+	prontoByte, err := Broadlink2Pronto(broadlinkByte, prontoIrFrequency)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
 
 	return prontoByte
 }
 
-func pronto2lirc(prontoCode []byte) []int {
-	codes := make([]uint16, len(prontoCode)/2)
+// Pronto2Broadlink converts pronto codes to broadlink code.
+// Learned codes are send with their once sequence followed by their repeat sequence, a repeat sequence equal to the once sequence sets the repeat byte.
+// Codes of the predefined formats for RC5, RC6 and NEC are encoded by their protocol.
+func Pronto2Broadlink(prontoByte []byte) ([]byte, error) {
+	code, err := ProntoIRCode(prontoByte)
+	if err == nil {
+		return EncodeIR(code)
+	} else if !errors.Is(err, ErrUnknownProtocol) {
+		return nil, err
+	}
 
-	for i := 0; i < len(prontoCode); i = i + 2 {
-		codes[i/2] = binary.BigEndian.Uint16(prontoCode[i : i+2])
+	once, repeat, err := pronto2lirc(prontoByte)
+	if err != nil {
+		return nil, err
 	}
 
-	if codes[0] != 0 {
-		log.Fatal("Pronto code should start with 0000")
+	switch {
+	case len(once) == 0:
+		return lirc2broadlink(repeat)
+	case len(repeat) == 0:
+		return lirc2broadlink(once)
+	case reflect.DeepEqual(once, repeat):
+		broadlinkCode, err := lirc2broadlink(once)
+		if err != nil {
+			return nil, err
+		}
+		broadlinkCode[1] = 1
+		return broadlinkCode, nil
 	}
 
-	if uint32(len(codes)) != 4+2*(binary.BigEndian.Uint32(prontoCode[4:])) {
-		log.Fatal("Number of pulse widths does not match the preamble")
+	return lirc2broadlink(append(once, repeat...))
+}
+
+// Broadlink2Pronto converts broadlink codes to pronto code, a repeated code becomes the once and the repeat sequence
+func Broadlink2Pronto(broadlinkByte []byte, prontoIrFrequency uint16) ([]byte, error) {
+	if prontoIrFrequency == 0 {
+		return nil, errors.New("invalid pronto frequency word 0000")
 	}
 
-	frequency := 1 / (float64(codes[1]) * 0.241246)
-	lircCode := make([]int, len(codes)-4)
-	for i := 4; i < len(codes); i++ {
-		lircCode[i-4] = int(math.Round(float64(codes[i]) / frequency))
+	lircCode, err := irPulses(broadlinkByte)
+	if err != nil {
+		return nil, err
 	}
 
-	return lircCode
-}
+	// pronto sequences are pairs of pulse and space
+	if len(lircCode)%2 != 0 {
+		lircCode = append(lircCode, defaultGap)
+	}
 
-func lirc2broadlink(lircCode []int) []byte {
-	var pulses []byte
+	if broadlinkByte[1] != 0 {
+		return lirc2pronto(lircCode, lircCode, prontoIrFrequency), nil
+	}
 
-	for i := 0; i < len(lircCode); i++ {
-		pulse := lircCode[i] * 269 / 8192
+	return lirc2pronto(lircCode, nil, prontoIrFrequency), nil
+}
 
-		if pulse < 256 {
-			pulses = append(pulses, byte(pulse))
-		} else {
-			pulses = append(pulses, byte(0))
-			pulseByte := make([]byte, 2)
-			binary.BigEndian.PutUint16(pulseByte, uint16(pulse))
-			pulses = append(pulses, pulseByte[0], pulseByte[1])
-		}
+// ProntoFrequency returns the carrier frequency in Hz of a pronto code, 0 if the code has none
+func ProntoFrequency(prontoByte []byte) uint {
+	if len(prontoByte) < 4 || binary.BigEndian.Uint16(prontoByte[2:]) == 0 {
+		return 0
 	}
 
-	var packet []byte
-	packet = append(packet, 0x26, 0x00)
-	packetLen := make([]byte, 2)
-	binary.LittleEndian.PutUint16(packetLen, uint16(len(pulses)))
-	packet = append(packet, packetLen[0], packetLen[1])
-	packet = append(packet, pulses...)
-	packet = append(packet, 0x0d, 0x05)
+	return uint(math.Round(1000000 / (float64(binary.BigEndian.Uint16(prontoByte[2:])) * 0.241246)))
+}
+
+// ProntoFrequencyWord converts a frequency in Hz into the frequency word of the pronto format, 0 Hz is taken as 38 kHz
+func ProntoFrequencyWord(frequency uint) uint16 {
+	if frequency == 0 {
+		frequency = 38000
+	}
 
-	return packet
+	return uint16(math.Round(1000000 / (float64(frequency) * 0.241246)))
 }
 
-func broadlink2lirc(broadlinkCode []byte) []int {
-	if uint16(broadlinkCode[0]+broadlinkCode[1]) != 0x26 {
-		log.Fatal("Broadlink code do not start with 0x26 0x00")
+// pronto2lirc returns the once and the repeat sequence of a learned pronto code
+func pronto2lirc(prontoCode []byte) (once []int, repeat []int, err error) {
+	if len(prontoCode) < 8 || len(prontoCode)%2 != 0 {
+		return nil, nil, fmt.Errorf("invalid pronto code length %d", len(prontoCode))
+	}
+
+	codes := make([]uint16, len(prontoCode)/2)
+	for i := 0; i < len(prontoCode); i = i + 2 {
+		codes[i/2] = binary.BigEndian.Uint16(prontoCode[i : i+2])
+	}
+
+	if codes[0] != prontoLearned && codes[0] != prontoUnmodulated {
+		return nil, nil, fmt.Errorf("pronto format %04x not supported", codes[0])
+	}
+
+	if codes[1] == 0 {
+		return nil, nil, errors.New("invalid pronto frequency word 0000")
 	}
 
-	pulseLen := binary.LittleEndian.Uint16(broadlinkCode[2:4])
-	pulses := broadlinkCode[4 : pulseLen+4]
-	var lircCode []int
+	onceLen, repeatLen := 2*int(codes[2]), 2*int(codes[3])
+	if len(codes) != 4+onceLen+repeatLen {
+		return nil, nil, fmt.Errorf("pronto code has %d pulse widths, the preamble announces %d", len(codes)-4, onceLen+repeatLen)
+	}
 
-	for i := 0; i < len(pulses); i++ {
-		if pulses[i] == 0 {
-			i++
-			pulse := int(binary.BigEndian.Uint16(pulses[i:i+2])) * 8192 / 269
-			lircCode = append(lircCode, pulse)
-			i++
-		} else {
-			pulse := int(byte(pulses[i])) * 8192 / 269
-			lircCode = append(lircCode, pulse)
+	frequency := 1 / (float64(codes[1]) * 0.241246)
+	sequence := func(codes []uint16) (lircCode []int) {
+		for i := 0; i < len(codes); i++ {
+			units := int(codes[i])
+			// a value of zero length joins the durations around it
+			for i+2 < len(codes) && codes[i+1] == 0 {
+				units += int(codes[i+2])
+				i += 2
+			}
+			lircCode = append(lircCode, int(math.Round(float64(units)/frequency)))
 		}
+		return
 	}
 
-	return lircCode
+	return sequence(codes[4 : 4+onceLen]), sequence(codes[4+onceLen:]), nil
 }
 
-func lirc2pronto(lircCode []int, ff uint16) []byte {
-	frequency := 1 / (float64(ff) * 0.241246)
+func lirc2broadlink(lircCode []int) ([]byte, error) {
+	return Packet{Kind: PacketIR, Pulses: lircCode}.Marshal()
+}
 
-	var prontoByte []uint16
-	prontoByte = append(prontoByte, uint16(0))
-	prontoByte = append(prontoByte, ff)
-	prontoByte = append(prontoByte, uint16(0))
-	prontoByte = append(prontoByte, uint16(33))
-	for i := 0; i < len(lircCode); i++ {
-		prontoByte = append(prontoByte, uint16(math.Round(float64(lircCode[i])*frequency)))
+// lirc2pronto returns a learned pronto code of the once and the repeat sequence.
+// Durations longer than 0xffff carrier periods are split by values of zero length.
+func lirc2pronto(once []int, repeat []int, ff uint16) []byte {
+	frequency := 1 / (float64(ff) * 0.241246)
+	sequence := func(lircCode []int) (units []uint16) {
+		for _, duration := range lircCode {
+			value := int(math.Round(float64(duration) * frequency))
+			for ; value > 0xffff; value -= 0xffff {
+				units = append(units, 0xffff, 0)
+			}
+			units = append(units, uint16(value))
+		}
+		return
 	}
-	prontoByte[3] = uint16(len(prontoByte)/2 - 2)
+
+	onceUnits, repeatUnits := sequence(once), sequence(repeat)
+	prontoByte := []uint16{prontoLearned, ff, uint16(len(onceUnits) / 2), uint16(len(repeatUnits) / 2)}
+	prontoByte = append(prontoByte, onceUnits...)
+	prontoByte = append(prontoByte, repeatUnits...)
 
 	var prontoCode []byte
 	for i := 0; i < len(prontoByte); i++ {
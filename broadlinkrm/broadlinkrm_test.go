@@ -0,0 +1,281 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prontoNECCode is NEC address 0x04 command 0x08 in learned pronto format
+const prontoNECCode = "0000 006d 0022 0000 0157 00ab 0016 0015 0016 0015 0016 0040 0016 0015 0016 0015 0016 0015 0016 0015 0016 0015 0016 0040 " +
+	"0016 0040 0016 0015 0016 0040 0016 0040 0016 0040 0016 0040 0016 0040 0016 0015 0016 0015 0016 0015 0016 0040 0016 0015 " +
+	"0016 0015 0016 0015 0016 0015 0016 0040 0016 0040 0016 0040 0016 0015 0016 0040 0016 0040 0016 0040 0016 0040 0016 06a4"
+
+func prontoBytes(t testing.TB, code string) []byte {
+	data, err := hex.DecodeString(strings.Replace(code, " ", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// deviceResponse returns the answer of a device to a request with the packet count and the encrypted payload
+func deviceResponse(request []byte, count uint16, payload []byte) []byte {
+	response := append([]byte(nil), request[:0x38]...)
+	binary.LittleEndian.PutUint16(response[0x20:], 0)
+	binary.LittleEndian.PutUint16(response[0x26:], 0x3ee)
+	binary.LittleEndian.PutUint16(response[0x28:], count)
+	encrypted, _ := encrypt(defaultKey, deviceIv, payload)
+	response = append(response, encrypted...)
+	binary.LittleEndian.PutUint16(response[0x20:], makeChecksum(response))
+
+	return response
+}
+
+func TestCommandDropsLateResponse(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	timeout := DefaultTimeout
+	DefaultTimeout = 2
+	defer func() { DefaultTimeout = timeout }()
+
+	go func() {
+		request := make([]byte, 2048)
+		count, addr, err := conn.ReadFromUDP(request)
+		if err != nil || count < 0x38 {
+			return
+		}
+
+		// the answer to an earlier request arrives first
+		sent := binary.LittleEndian.Uint16(request[0x28:])
+		conn.WriteToUDP(deviceResponse(request, sent-1, []byte{0, 0, 0, 0, 'o', 'l', 'd'}), addr)
+		conn.WriteToUDP(deviceResponse(request, sent, []byte{0, 0, 0, 0, 'n', 'e', 'w'}), addr)
+	}()
+
+	dev := Device{DeviceAddr: conn.LocalAddr().(*net.UDPAddr), DeviceType: 0x2737, deviceKey: defaultKey, deviceMac: [6]byte{1, 2, 3, 4, 5, 6}}
+	response := Command(1, nil, &dev)
+	if !bytes.HasPrefix(response, []byte("new")) {
+		t.Errorf("got response %q, want the answer to the request", response)
+	}
+}
+
+func TestCommandSilentDeviceDoesNotBlock(t *testing.T) {
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer silent.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	timeout := DefaultTimeout
+	DefaultTimeout = 2
+	defer func() { DefaultTimeout = timeout }()
+
+	go func() {
+		request := make([]byte, 2048)
+		count, addr, err := conn.ReadFromUDP(request)
+		if err != nil || count < 0x38 {
+			return
+		}
+
+		conn.WriteToUDP(deviceResponse(request, binary.LittleEndian.Uint16(request[0x28:]), []byte{0, 0, 0, 0, 'o', 'k'}), addr)
+	}()
+
+	// the silent device is waited for until the timeout
+	done := make(chan bool)
+	defer func() { <-done }()
+	go func() {
+		dev := Device{DeviceAddr: silent.LocalAddr().(*net.UDPAddr), DeviceType: 0x2737, deviceKey: defaultKey, deviceMac: [6]byte{6, 5, 4, 3, 2, 1}}
+		Command(1, nil, &dev)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	dev := Device{DeviceAddr: conn.LocalAddr().(*net.UDPAddr), DeviceType: 0x2737, deviceKey: defaultKey, deviceMac: [6]byte{1, 2, 3, 4, 5, 6}}
+	if response := Command(1, nil, &dev); !bytes.HasPrefix(response, []byte("ok")) {
+		t.Errorf("got response %q", response)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("answer took %v while another device did not answer", elapsed)
+	}
+}
+
+func TestPronto2Broadlink(t *testing.T) {
+	packet, err := Pronto2Broadlink(prontoBytes(t, prontoNECCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := DecodeIR(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Protocol != "NEC" || code.Address != 0x04 || code.Command != 0x08 || !code.Valid {
+		t.Errorf("got %v, want NEC addr=0x04 cmd=0x08", code)
+	}
+
+	pronto, err := Broadlink2Pronto(packet, 0x6d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Pronto2Broadlink(pronto); err != nil || hex.EncodeToString(again) != hex.EncodeToString(packet) {
+		t.Errorf("pronto round trip changed the code: %x, %v", again, err)
+	}
+}
+
+func TestPronto2BroadlinkInvalid(t *testing.T) {
+	tests := map[string]string{
+		"too short":        "0000 006d 0000",
+		"odd length":       "0000 006d 0001 0000 0157 00",
+		"unknown format":   "1234 006d 0001 0000 0157 00ab",
+		"no frequency":     "0000 0000 0001 0000 0157 00ab",
+		"length mismatch":  "0000 006d 0002 0000 0157 00ab",
+		"predefined short": "5000 0073 0000 0001 0001",
+	}
+
+	for name, code := range tests {
+		if _, err := Pronto2Broadlink(prontoBytes(t, code)); err == nil {
+			t.Errorf("%v: no error for %v", name, code)
+		}
+	}
+}
+
+func TestBroadlink2ProntoInvalid(t *testing.T) {
+	tests := map[string]string{
+		"too short":  "2600",
+		"RF code":    "b2000400" + "1212" + "0d05",
+		"no trailer": "26000200" + "1212" + "0000",
+	}
+
+	for name, code := range tests {
+		data, _ := hex.DecodeString(code)
+		if _, err := Broadlink2Pronto(data, 0x6d); err == nil {
+			t.Errorf("%v: no error for %v", name, code)
+		}
+	}
+
+	if _, err := Broadlink2Pronto(prontoBytes(t, "26000200 1212 0d05"), 0); err == nil {
+		t.Error("no error for the frequency word 0")
+	}
+}
+
+func TestBroadlink2ProntoLongGap(t *testing.T) {
+	// 2 s are 76058 periods of 38 kHz, split into 0xffff periods, a value of zero length and the remaining 0x291b periods
+	packet, err := Packet{Kind: PacketIR, Pulses: []int{9000, 2000000}}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pronto, err := Broadlink2Pronto(packet, 0x6d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := prontoBytes(t, "0000 006d 0002 0000 0157 ffff 0000 291b"); !bytes.Equal(pronto, want) {
+		t.Fatalf("got %x, want %x", pronto, want)
+	}
+
+	again, err := Pronto2Broadlink(pronto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := ParsePacket(again); err != nil || len(parsed.Pulses) != 2 || parsed.Pulses[1] < 1999000 || parsed.Pulses[1] > 2001000 {
+		t.Errorf("got pulses %v (%v), want a 2 s gap", parsed.Pulses, err)
+	}
+}
+
+func TestDeprecatedConvertersInvalid(t *testing.T) {
+	// the deprecated converters log invalid codes instead of exiting
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	if packet := ConvertPronto2Broadlink(prontoBytes(t, "0000 006d 0002 0000 0157 00ab")); packet != nil {
+		t.Errorf("got %x for an invalid pronto code", packet)
+	}
+	if pronto := ConvertBroadlink2Pronto(prontoBytes(t, "26000200 1212 0000"), 0x6d); pronto != nil {
+		t.Errorf("got %x for an invalid packet", pronto)
+	}
+}
+
+func FuzzPronto2Broadlink(f *testing.F) {
+	f.Add(prontoBytes(f, prontoNECCode))
+	f.Add(prontoBytes(f, "5000 0073 0000 0001 0001 0001"))
+	f.Add(prontoBytes(f, "900a 006d 0000 0001 04fb 01fe"))
+	f.Add(prontoBytes(f, "0000 006d 0001 0001 0157 00ab 0157 00ab"))
+
+	f.Fuzz(func(t *testing.T, pronto []byte) {
+		packet, err := Pronto2Broadlink(pronto)
+		if err != nil {
+			return
+		}
+
+		if _, err := ParsePacket(packet); err != nil {
+			t.Errorf("pronto code %x converted to invalid packet %x: %v", pronto, packet, err)
+		}
+	})
+}
+
+func FuzzBroadlink2Pronto(f *testing.F) {
+	packet, err := Pronto2Broadlink(prontoBytes(f, prontoNECCode))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(packet, uint16(0x6d))
+	f.Add(prontoBytes(f, "26010400 1212 0d05"), uint16(0x73))
+	f.Add(prontoBytes(f, "26000a00 12 00ffff 000000 0080d0 0d05"), uint16(0x6d))
+
+	f.Fuzz(func(t *testing.T, packet []byte, word uint16) {
+		pronto, err := Broadlink2Pronto(packet, word)
+		if err != nil {
+			return
+		}
+
+		if _, _, err := pronto2lirc(pronto); err != nil {
+			t.Errorf("packet %x converted to invalid pronto code %x: %v", packet, pronto, err)
+		}
+	})
+}
+
+func TestProntoFrequency(t *testing.T) {
+	tests := map[uint]uint16{36000: 0x0073, 38000: 0x006d, 40000: 0x0068, 56000: 0x004a}
+
+	for frequency, word := range tests {
+		if got := ProntoFrequencyWord(frequency); got != word {
+			t.Errorf("%d Hz: got word %04x, want %04x", frequency, got, word)
+		}
+
+		// the word rounds the frequency to about 1%
+		pronto := []byte{0x00, 0x00, byte(word >> 8), byte(word)}
+		if got := ProntoFrequency(pronto); got < frequency*99/100 || got > frequency*101/100 {
+			t.Errorf("word %04x: got %d Hz, want about %d Hz", word, got, frequency)
+		}
+	}
+
+	if got := ProntoFrequencyWord(0); got != 0x006d {
+		t.Errorf("0 Hz: got word %04x, want the word of 38 kHz", got)
+	}
+
+	if got := ProntoFrequency([]byte{0x00, 0x00, 0x00, 0x00}); got != 0 {
+		t.Errorf("got %d Hz for frequency word 0", got)
+	}
+}
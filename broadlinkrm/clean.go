@@ -0,0 +1,194 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// cleanFrameGap is the shortest space in µs between two frames of a code
+const cleanFrameGap = 10000
+
+// cleanMinFrame is the least number of durations of a frame, shorter frames are noise
+const cleanMinFrame = 4
+
+// cleanHeaderRatio is how many times longer than the median pulse a header pulse is at least
+const cleanHeaderRatio = 3
+
+// CleanPacket removes the jitter and noise of a learned code in Broadlink format.
+// Pulse and space widths are snapped to the mean of their cluster, frames too short to carry a code
+// a partial frame at the end and glitches before the first header pulse are removed. If frames repeat, only one full frame or sequence of frames
+// and its first repeat are kept.
+func CleanPacket(data []byte) ([]byte, error) {
+	packet, err := ParsePacket(data)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := cleanFrames(trimLeadingNoise(snapWidths(packet.Pulses)))
+	if len(frames) == 0 {
+		return nil, errors.New("broadlink code contains no frame")
+	}
+
+	packet.Pulses = nil
+	for _, frame := range frames {
+		packet.Pulses = append(packet.Pulses, frame...)
+	}
+
+	return packet.Marshal()
+}
+
+// snapWidths replaces the pulse and space durations shorter than a frame gap by the mean of their cluster
+func snapWidths(pulses []int) []int {
+	snapped := append([]int(nil), pulses...)
+
+	for kind := 0; kind < 2; kind++ {
+		var widths []int
+		for i := kind; i < len(pulses); i += 2 {
+			if pulses[i] < cleanFrameGap {
+				widths = append(widths, pulses[i])
+			}
+		}
+		sort.Ints(widths)
+
+		// clusters start at the shortest width not matching the previous cluster
+		canonical := map[int]int{}
+		for start := 0; start < len(widths); {
+			end, sum := start, 0
+			for ; end < len(widths) && matchDuration(widths[end], widths[start]); end++ {
+				sum += widths[end]
+			}
+
+			mean := int(math.Round(float64(sum) / float64(end-start)))
+			for _, width := range widths[start:end] {
+				canonical[width] = mean
+			}
+			start = end
+		}
+
+		for i := kind; i < len(snapped); i += 2 {
+			if width, ok := canonical[snapped[i]]; ok {
+				snapped[i] = width
+			}
+		}
+	}
+
+	return snapped
+}
+
+// trimLeadingNoise removes the durations before the first header pulse, a pulse several times longer than
+// the median pulse, together with the glitches joined to the first frame by a short space.
+// Codes without header pulse, e.g. RC5, are returned unchanged.
+func trimLeadingNoise(pulses []int) []int {
+	var widths []int
+	for i := 0; i < len(pulses); i += 2 {
+		widths = append(widths, pulses[i])
+	}
+	if len(widths) < cleanMinFrame {
+		return pulses
+	}
+	sort.Ints(widths)
+	median := widths[len(widths)/2]
+
+	for i := 0; i < len(pulses); i += 2 {
+		if pulses[i] >= cleanHeaderRatio*median {
+			return pulses[i:]
+		}
+	}
+
+	return pulses
+}
+
+// cleanFrames splits pulses at the frame gaps and returns the frames to keep
+func cleanFrames(pulses []int) [][]int {
+	var frames [][]int
+	start := 0
+	for i := 1; i < len(pulses); i += 2 {
+		if pulses[i] >= cleanFrameGap || i == len(pulses)-1 {
+			frames = append(frames, pulses[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(pulses) {
+		frames = append(frames, pulses[start:])
+	}
+
+	var kept [][]int
+	for _, frame := range frames {
+		if len(frame) >= cleanMinFrame {
+			kept = append(kept, frame)
+		}
+	}
+	frames = kept
+
+	// a last frame matching the start of an earlier one was cut off while learning
+	if last := len(frames) - 1; last > 0 {
+		for _, frame := range frames[:last] {
+			if len(frames[last]) < len(frame) && matchFrame(frames[last], frame[:len(frames[last])]) {
+				frames = frames[:last]
+				break
+			}
+		}
+	}
+
+	// a last frame continuing past the end of an earlier one carries trailing noise
+	if last := len(frames) - 1; last > 0 {
+		for _, frame := range frames[:last] {
+			if len(frames[last]) > len(frame) && matchFrame(frames[last][:len(frame)], frame) {
+				frames[last] = append(frames[last][:len(frame)-1:len(frame)-1], frame[len(frame)-1])
+				break
+			}
+		}
+	}
+
+	// frames repeating with a period keep one period and its repeat
+	for period := 1; period <= len(frames)/2; period++ {
+		repeating := true
+		for i := period; i < len(frames) && repeating; i++ {
+			repeating = matchFrame(frames[i], frames[i-period])
+		}
+
+		if repeating {
+			return frames[:2*period]
+		}
+	}
+
+	// a frame followed by repeat frames differing from it, e.g. NEC, keeps one repeat frame
+	if len(frames) > 2 {
+		repeating := true
+		for _, frame := range frames[2:] {
+			repeating = repeating && matchFrame(frame, frames[1])
+		}
+
+		if repeating {
+			return frames[:2]
+		}
+	}
+
+	return frames
+}
+
+// matchFrame compares two frames ignoring the gap they end with
+func matchFrame(frame []int, other []int) bool {
+	if len(frame)%2 == 0 {
+		frame = frame[:len(frame)-1]
+	}
+	if len(other)%2 == 0 {
+		other = other[:len(other)-1]
+	}
+
+	if len(frame) != len(other) {
+		return false
+	}
+
+	for i := range frame {
+		if !matchDuration(frame[i], other[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,118 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+// jitteredNEC returns the durations of a NEC frame of address 0x04 and command 0x08 as learned,
+// the marks are 540, 560 or 580 µs and the spaces vary likewise
+func jitteredNEC(gap int) []int {
+	jitter := []int{-20, 0, 20}
+	pulses := []int{9040, 4480}
+	data := uint32(0x04) | uint32(^uint8(0x04))<<8 | uint32(0x08)<<16 | uint32(^uint8(0x08))<<24
+	for bit := 0; bit < 32; bit++ {
+		space := 560
+		if data&(1<<bit) != 0 {
+			space = 1690
+		}
+		pulses = append(pulses, 560+jitter[bit%3], space+jitter[(bit+1)%3])
+	}
+
+	return append(pulses, 580, gap)
+}
+
+// cleanIR cleans the IR packet of pulses and decodes the result
+func cleanIR(t *testing.T, pulses []int) (IRCode, Packet) {
+	t.Helper()
+	data, err := Packet{Kind: PacketIR, Pulses: pulses}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleaned, err := CleanPacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := ParsePacket(cleaned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := DecodeIR(cleaned)
+	if err != nil {
+		t.Fatalf("cleaned code does not decode: %v", err)
+	}
+
+	return code, packet
+}
+
+func TestCleanPacketSnapsJitter(t *testing.T) {
+	code, packet := cleanIR(t, jitteredNEC(40000))
+	if code.String() != "NEC addr=0x04 cmd=0x08" {
+		t.Errorf("got %v", code)
+	}
+
+	for i := 2; i < len(packet.Pulses)-1; i += 2 {
+		if d := packet.Pulses[i] - packet.Pulses[2]; d < -31 || d > 31 {
+			t.Fatalf("mark %d is %d µs, want %d µs like the first mark", i, packet.Pulses[i], packet.Pulses[2])
+		}
+	}
+}
+
+func TestCleanPacketNoise(t *testing.T) {
+	frame := jitteredNEC(40000)
+	tests := []struct {
+		name   string
+		pulses []int
+	}{
+		{"leading glitches", append([]int{120, 340, 90, 600}, frame...)},
+		{"leading noise frame", append([]int{150, 200, 130, 300, 110, 20000}, frame...)},
+		{"trailing partial frame", append(append(append([]int(nil), frame...), frame...), frame[:20]...)},
+		{"trailing glitches", append(append(append([]int(nil), frame...), frame[:len(frame)-1]...), 700, 130, 40000)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, packet := cleanIR(t, test.pulses)
+			if code.String() != "NEC addr=0x04 cmd=0x08" {
+				t.Errorf("%v: got %v", test.name, code)
+			}
+
+			if n := len(packet.Pulses); n != len(frame) && n != 2*len(frame) {
+				t.Errorf("%v: got %d durations, want whole frames of %d", test.name, n, len(frame))
+			}
+			if packet.Pulses[0] < 8500 {
+				t.Errorf("%v: got first pulse %d µs, want the header", test.name, packet.Pulses[0])
+			}
+		})
+	}
+}
+
+func TestCleanPacketKeepsHeaderlessCodes(t *testing.T) {
+	packet, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c})
+	cleaned, err := CleanPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, err := DecodeIR(cleaned); err != nil || code.String() != "RC5 addr=0x05 cmd=0x0c" {
+		t.Errorf("got %v (%v)", code, err)
+	}
+}
+
+func TestCleanPacketRepeatFrames(t *testing.T) {
+	repeat := []int{9000, 2250, 560, 96000}
+	pulses := jitteredNEC(40000)
+	for i := 0; i < 4; i++ {
+		pulses = append(pulses, repeat...)
+	}
+
+	_, packet := cleanIR(t, pulses)
+	if len(packet.Pulses) != 68+4 {
+		t.Errorf("got %d durations, want the frame and one repeat frame", len(packet.Pulses))
+	}
+}
@@ -0,0 +1,261 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the codes stored in a code library
+const (
+	FormatBroadlink = "broadlink"
+	FormatPronto    = "pronto"
+)
+
+// ErrCodeNotFound is returned if a code name is not in the code library
+var ErrCodeNotFound = errors.New("code not found")
+
+// Code holds an IR or RF code with its metadata
+type Code struct {
+	// Format of Data, see the Format constants
+	Format string `json:"format" yaml:"format"`
+	// Data is the hex encoded code
+	Data string `json:"data" yaml:"data"`
+	// Frequency in Hz of the IR carrier, 0 if unknown
+	Frequency uint `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	// Repeat count of the code, it replaces the repeat count of the packet in Data
+	Repeat uint8 `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+	// LearnedFrom is the mac or alias of the device the code was learned with
+	LearnedFrom string `json:"learned_from,omitempty" yaml:"learned_from,omitempty"`
+	// Learned is the time the code was learned
+	Learned time.Time `json:"learned,omitzero" yaml:"learned,omitempty"`
+}
+
+// Remote holds the buttons of one remote controlled device, e.g. "living room TV"
+type Remote struct {
+	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Buttons     map[string]*Code `json:"buttons" yaml:"buttons"`
+}
+
+// CodeLibrary holds named codes organised in remotes and their buttons.
+// Codes are addressed by the name "remote/button".
+type CodeLibrary struct {
+	Remotes map[string]*Remote `json:"remotes" yaml:"remotes"`
+}
+
+// NewBroadlinkCode creates a code from a packet in Broadlink format as returned by learning.
+// The frequency is inferred from the IR protocol of the packet.
+func NewBroadlinkCode(packet []byte) Code {
+	code := Code{Format: FormatBroadlink, Data: hex.EncodeToString(packet), Frequency: InferFrequency(packet)}
+	if len(packet) > 1 {
+		code.Repeat = packet[1]
+	}
+
+	return code
+}
+
+// NewCode creates a code from data in the format, the repeat count and the frequency are taken from the data
+func NewCode(format string, data []byte) (Code, error) {
+	code := Code{Format: format, Data: hex.EncodeToString(data)}
+	packet, err := code.packet()
+	if err != nil {
+		return code, err
+	}
+
+	code.Repeat = packet[1]
+	code.Frequency = InferFrequency(packet)
+	if format == FormatPronto {
+		code.Frequency = ProntoFrequency(data)
+	}
+
+	return code, nil
+}
+
+// Packet returns the code in Broadlink format ready to be send with Command(2, ...), the repeat count is the one of the code
+func (code Code) Packet() ([]byte, error) {
+	packet, err := code.packet()
+	if err != nil {
+		return nil, err
+	}
+
+	packet[1] = code.Repeat
+	return packet, nil
+}
+
+// packet converts the data of the code into a packet in Broadlink format
+func (code Code) packet() ([]byte, error) {
+	data, err := hex.DecodeString(strings.Replace(code.Data, " ", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid code data: %v", err)
+	}
+
+	switch code.Format {
+	case FormatBroadlink, "":
+		if len(data) < 2 {
+			return nil, fmt.Errorf("broadlink code too short: %d bytes", len(data))
+		}
+		return data, nil
+	case FormatPronto:
+		return Pronto2Broadlink(data)
+	}
+
+	return nil, fmt.Errorf("unsupported code format %q", code.Format)
+}
+
+// SplitCodeName splits a code name "remote/button" into its parts
+func SplitCodeName(name string) (remote string, button string, err error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid code name %q - expected remote/button", name)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// NewCodeLibrary creates an empty code library
+func NewCodeLibrary() *CodeLibrary {
+	return &CodeLibrary{Remotes: make(map[string]*Remote)}
+}
+
+// LoadCodeLibrary reads a code library from a JSON or YAML file, the format is selected by the file extension.
+func LoadCodeLibrary(path string) (*CodeLibrary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := NewCodeLibrary()
+	if isJSONFile(path) {
+		err = json.Unmarshal(data, lib)
+	} else {
+		err = yaml.Unmarshal(data, lib)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("code library %v: %v", path, err)
+	}
+
+	if lib.Remotes == nil {
+		lib.Remotes = make(map[string]*Remote)
+	}
+
+	return lib, nil
+}
+
+// Save writes the code library to a JSON or YAML file, the format is selected by the file extension.
+func (lib *CodeLibrary) Save(path string) error {
+	var data []byte
+	var err error
+	if isJSONFile(path) {
+		data, err = json.MarshalIndent(lib, "", "  ")
+	} else {
+		data, err = yaml.Marshal(lib)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Get returns the code with the name "remote/button"
+func (lib *CodeLibrary) Get(name string) (*Code, error) {
+	remoteName, button, err := SplitCodeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote, found := lib.Remotes[remoteName]; found {
+		if code, found := remote.Buttons[button]; found {
+			return code, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrCodeNotFound, name)
+}
+
+// Set stores code under the name "remote/button", the remote is created if needed
+func (lib *CodeLibrary) Set(name string, code Code) error {
+	remoteName, button, err := SplitCodeName(name)
+	if err != nil {
+		return err
+	}
+
+	remote, found := lib.Remotes[remoteName]
+	if !found {
+		remote = &Remote{}
+		lib.Remotes[remoteName] = remote
+	}
+
+	if remote.Buttons == nil {
+		remote.Buttons = make(map[string]*Code)
+	}
+
+	remote.Buttons[button] = &code
+	return nil
+}
+
+// Delete removes the code with the name "remote/button", remotes without buttons are removed too
+func (lib *CodeLibrary) Delete(name string) error {
+	remoteName, button, err := SplitCodeName(name)
+	if err != nil {
+		return err
+	}
+
+	remote, found := lib.Remotes[remoteName]
+	if !found || remote.Buttons[button] == nil {
+		return fmt.Errorf("%w: %v", ErrCodeNotFound, name)
+	}
+
+	delete(remote.Buttons, button)
+	if len(remote.Buttons) == 0 {
+		delete(lib.Remotes, remoteName)
+	}
+
+	return nil
+}
+
+// Names returns the sorted names of all codes in the library
+func (lib *CodeLibrary) Names() (names []string) {
+	for remoteName, remote := range lib.Remotes {
+		for button := range remote.Buttons {
+			names = append(names, remoteName+"/"+button)
+		}
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// Merge adds all codes of other to the library, existing codes are replaced
+func (lib *CodeLibrary) Merge(other *CodeLibrary) {
+	for remoteName, remote := range other.Remotes {
+		if _, found := lib.Remotes[remoteName]; !found {
+			lib.Remotes[remoteName] = &Remote{Description: remote.Description}
+		}
+
+		for button, code := range remote.Buttons {
+			lib.Set(remoteName+"/"+button, *code)
+		}
+	}
+}
+
+func isJSONFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
@@ -0,0 +1,95 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCodePacketRepeat(t *testing.T) {
+	packet, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c, Repeats: 2})
+	code := NewBroadlinkCode(packet)
+	if code.Repeat != 2 || code.Frequency != 36000 {
+		t.Errorf("got repeat %d and frequency %d, want 2 and 36000", code.Repeat, code.Frequency)
+	}
+
+	// an edited repeat count replaces the one of the packet
+	code.Repeat = 5
+	if sent, err := code.Packet(); err != nil || sent[1] != 5 {
+		t.Errorf("got packet %x (%v), want repeat 5", sent, err)
+	}
+
+	pronto, err := NewCode(FormatPronto, prontoBytes(t, "0000 006d 0001 0001 0157 00ab 0157 00ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pronto.Repeat != 1 || pronto.Frequency != 38029 {
+		t.Errorf("got repeat %d and frequency %d of the pronto code", pronto.Repeat, pronto.Frequency)
+	}
+
+	pronto.Repeat = 3
+	if sent, err := pronto.Packet(); err != nil || sent[1] != 3 {
+		t.Errorf("got packet %x (%v), want repeat 3", sent, err)
+	}
+}
+
+func TestNewCodeInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"broadlink": {0x26},
+		"pronto":    {0x00, 0x00, 0x00},
+		"unknown":   {0x26, 0x00, 0x00, 0x00, 0x0d, 0x05},
+	}
+
+	for format, data := range tests {
+		if _, err := NewCode(format, data); err == nil {
+			t.Errorf("%v: no error for %x", format, data)
+		}
+	}
+}
+
+func TestCodeLibrary(t *testing.T) {
+	packet, _ := EncodeIR(IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	lib := NewCodeLibrary()
+	for _, name := range []string{"tv/power", "tv/mute", "avr/power"} {
+		if err := lib.Set(name, NewBroadlinkCode(packet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lib.Set("power", NewBroadlinkCode(packet)); err == nil {
+		t.Error("no error for a name without remote")
+	}
+
+	if names := lib.Names(); !reflect.DeepEqual(names, []string{"avr/power", "tv/mute", "tv/power"}) {
+		t.Errorf("got names %v", names)
+	}
+
+	if err := lib.Delete("avr/power"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := lib.Remotes["avr"]; found {
+		t.Error("remote without buttons was kept")
+	}
+	if _, err := lib.Get("avr/power"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("got %v, want ErrCodeNotFound", err)
+	}
+
+	for _, file := range []string{"codes.yaml", "codes.json"} {
+		path := filepath.Join(t.TempDir(), file)
+		if err := lib.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadCodeLibrary(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, lib) {
+			t.Errorf("%v: got %+v, want %+v", file, loaded, lib)
+		}
+	}
+}
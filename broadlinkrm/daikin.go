@@ -0,0 +1,60 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Daikin ARC remote timings in µs
+const (
+	daikinHeaderPulse = 3650
+	daikinHeaderSpace = 1623
+	daikinBitPulse    = 428
+	daikinOneSpace    = 1280
+	daikinZeroSpace   = 428
+	daikinGap         = 29000
+)
+
+// daikinModel are the 280 bit codes of Daikin ARC433 and ARC470 remotes, send as 5 bit preamble and three sections
+var daikinModel = acModel{
+	names:          []string{"daikin", "daikin-arc"},
+	minTemperature: 10,
+	maxTemperature: 32,
+	modes:          map[string]uint32{"auto": 0, "dry": 2, "cool": 3, "heat": 4, "fan": 6},
+	fans:           map[string]uint32{"auto": 0xa, "low": 3, "medium": 5, "high": 7, "quiet": 0xb},
+	encode:         encodeDaikin,
+}
+
+func encodeDaikin(state ACState, mode uint32, fan uint32) []int {
+	sections := [][]byte{
+		{0x11, 0xda, 0x27, 0x00, 0xc5, 0x00, 0x00, 0x00},
+		{0x11, 0xda, 0x27, 0x00, 0x42, 0x00, 0x00, 0x00},
+		{0x11, 0xda, 0x27, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x60, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00},
+	}
+
+	state3 := sections[2]
+	state3[5] |= byte(mode) << 4
+	if state.Power {
+		state3[5] |= 1
+	}
+	state3[6] = byte(state.Temperature * 2)
+	state3[8] = byte(fan) << 4
+	if state.Swing {
+		state3[8] |= 0x0f
+	}
+
+	var train pulseTrain
+	train.spaceBits(0, 5, daikinBitPulse, daikinOneSpace, daikinZeroSpace)
+	train.add(true, daikinBitPulse)
+	train.add(false, daikinZeroSpace+daikinGap)
+
+	for _, section := range sections {
+		section[len(section)-1] = sumBytes(section[:len(section)-1])
+
+		train.add(true, daikinHeaderPulse)
+		train.add(false, daikinHeaderSpace)
+		train.spaceBytes(section, daikinBitPulse, daikinOneSpace, daikinZeroSpace)
+		train.add(true, daikinBitPulse)
+		train.add(false, daikinZeroSpace+daikinGap)
+	}
+
+	return train
+}
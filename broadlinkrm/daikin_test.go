@@ -0,0 +1,34 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDaikin(t *testing.T) {
+	pulses := encodeDaikin(ACState{Power: true, Mode: "cool", Temperature: 25, Fan: "auto"}, 3, 0xa)
+
+	// 5 bit preamble, then three sections with header and gap
+	sections := []struct {
+		start int
+		want  []byte
+	}{
+		{14, []byte{0x11, 0xda, 0x27, 0x00, 0xc5, 0x00, 0x00, 0xd7}},
+		{14 + 132, []byte{0x11, 0xda, 0x27, 0x00, 0x42, 0x00, 0x00, 0x54}},
+		{14 + 2*132, []byte{0x11, 0xda, 0x27, 0x00, 0x00, 0x39, 0x32, 0x00, 0xa0, 0x00, 0x00, 0x06, 0x60, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x43}},
+	}
+
+	for i, section := range sections {
+		if !matchDuration(pulses[section.start-2], daikinHeaderPulse) {
+			t.Errorf("section %d: no header at %d", i+1, section.start-2)
+			continue
+		}
+
+		if data := readSpaceBytes(t, pulses[section.start:], len(section.want), daikinBitPulse, daikinOneSpace, daikinZeroSpace); !bytes.Equal(data, section.want) {
+			t.Errorf("section %d: got %x, want %x", i+1, data, section.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flipperFiletype is the header of a Flipper Zero IR signals file
+const flipperFiletype = "IR signals file"
+
+// FlipperSignal is a signal of a Flipper Zero .ir file, either raw or parsed with protocol, address and command
+type FlipperSignal struct {
+	Name      string
+	Type      string
+	Protocol  string
+	Address   uint32
+	Command   uint32
+	Frequency uint
+	DutyCycle float64
+	Data      []int
+}
+
+// ParseFlipperIR reads the signals of a Flipper Zero .ir file
+func ParseFlipperIR(r io.Reader) (signals []FlipperSignal, err error) {
+	var signal *FlipperSignal
+	lineNumber := 0
+	header := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: invalid line %q", lineNumber, line)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+
+		switch key {
+		case "filetype":
+			if value != flipperFiletype {
+				return nil, fmt.Errorf("unsupported filetype %q", value)
+			}
+			header = true
+			continue
+		case "version":
+			continue
+		case "name":
+			signals = append(signals, FlipperSignal{Name: value})
+			signal = &signals[len(signals)-1]
+			continue
+		}
+
+		if signal == nil {
+			return nil, fmt.Errorf("line %d: %v without a name", lineNumber, key)
+		}
+
+		switch key {
+		case "type":
+			signal.Type = value
+		case "protocol":
+			signal.Protocol = value
+		case "address", "command":
+			data, err := hex.DecodeString(strings.Replace(value, " ", "", -1))
+			if err != nil || len(data) > 4 {
+				return nil, fmt.Errorf("line %d: invalid %v %q", lineNumber, key, value)
+			}
+
+			number := binary.LittleEndian.Uint32(append(data, make([]byte, 4-len(data))...))
+			if key == "address" {
+				signal.Address = number
+			} else {
+				signal.Command = number
+			}
+		case "frequency":
+			frequency, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid frequency %q", lineNumber, value)
+			}
+			signal.Frequency = uint(frequency)
+		case "duty_cycle":
+			if signal.DutyCycle, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid duty cycle %q", lineNumber, value)
+			}
+		case "data":
+			for _, field := range strings.Fields(value) {
+				duration, err := strconv.Atoi(field)
+				if err != nil || duration < 0 {
+					return nil, fmt.Errorf("line %d: invalid duration %q", lineNumber, field)
+				}
+				signal.Data = append(signal.Data, duration)
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !header {
+		return nil, errors.New("no Flipper Zero IR signals file")
+	}
+
+	return signals, nil
+}
+
+// Code encodes the signal into a code in Broadlink format
+func (signal FlipperSignal) Code() (code Code, err error) {
+	var pulses []int
+	// the frequency of parsed signals is inferred from their protocol
+	var frequency uint
+	var repeat uint8
+
+	switch strings.ToLower(signal.Type) {
+	case "raw":
+		if len(signal.Data) == 0 {
+			return code, fmt.Errorf("signal %v has no data", signal.Name)
+		}
+
+		var train pulseTrain
+		for i, duration := range signal.Data {
+			train.add(i%2 == 0, duration)
+		}
+		train.add(false, defaultGap)
+		pulses = train
+
+		frequency = 38000
+		if signal.Frequency != 0 {
+			frequency = signal.Frequency
+		}
+	case "parsed":
+		switch signal.Protocol {
+		case "NEC", "NECext":
+			pulses = encodeNEC(signal.Address, signal.Command, signal.Protocol == "NECext", 0)
+		case "Samsung32":
+			pulses = encodeSamsung(signal.Address, signal.Command)
+		case "RC5", "RC5X":
+			pulses = encodeRC5(signal.Address, signal.Command, false)
+		case "SIRC", "SIRC15", "SIRC20":
+			// the address of SIRC20 signals holds the extended device field in bits 5-12
+			packet, err := EncodeIR(IRCode{Protocol: signal.Protocol, Address: signal.Address & 0x1fff, Command: signal.Command})
+			if signal.Protocol == "SIRC20" {
+				packet, err = EncodeIR(IRCode{Protocol: signal.Protocol, Address: signal.Address & 0x1f, Extended: signal.Address >> 5, Command: signal.Command})
+			}
+			if err != nil {
+				return code, err
+			}
+
+			return NewBroadlinkCode(packet), nil
+		default:
+			return code, fmt.Errorf("signal %v: protocol %v not supported", signal.Name, signal.Protocol)
+		}
+	default:
+		return code, fmt.Errorf("signal %v: unsupported type %q", signal.Name, signal.Type)
+	}
+
+	packet, err := lirc2broadlink(pulses)
+	if err != nil {
+		return code, fmt.Errorf("signal %v: %v", signal.Name, err)
+	}
+	packet[1] = repeat
+	code = NewBroadlinkCode(packet)
+	if frequency != 0 {
+		code.Frequency = frequency
+	}
+	return code, nil
+}
+
+// ImportFlipperIR reads a Flipper Zero .ir file into a code library, all signals become buttons of the given remote
+func ImportFlipperIR(r io.Reader, remote string) (*CodeLibrary, error) {
+	signals, err := ParseFlipperIR(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := NewCodeLibrary()
+	for _, signal := range signals {
+		code, err := signal.Code()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = lib.Set(remote+"/"+signal.Name, code); err != nil {
+			return nil, err
+		}
+	}
+
+	return lib, nil
+}
+
+// ExportFlipperIR writes the IR codes of a remote as raw signals of a Flipper Zero .ir file
+func ExportFlipperIR(w io.Writer, remote *Remote) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "Filetype: %v\nVersion: 1\n", flipperFiletype)
+
+	var buttons []string
+	for button := range remote.Buttons {
+		buttons = append(buttons, button)
+	}
+	sort.Strings(buttons)
+
+	for _, button := range buttons {
+		code := remote.Buttons[button]
+		packet, err := code.Packet()
+		if err != nil {
+			return fmt.Errorf("code %v: %v", button, err)
+		}
+
+		frame, err := irPulses(packet)
+		if err != nil {
+			return fmt.Errorf("code %v: %v", button, err)
+		}
+
+		// raw signals have no repeat count, the frame is repeated instead
+		var pulses pulseTrain
+		for i := 0; i <= int(packet[1]); i++ {
+			for j, duration := range frame {
+				pulses.add(j%2 == 0, duration)
+			}
+
+			if len(pulses)%2 != 0 {
+				pulses.add(false, defaultGap)
+			}
+		}
+
+		// Flipper signals end with a pulse
+		if len(pulses)%2 == 0 {
+			pulses = pulses[:len(pulses)-1]
+		}
+
+		frequency := code.Frequency
+		if frequency == 0 {
+			frequency = 38000
+		}
+
+		data := make([]string, len(pulses))
+		for i, duration := range pulses {
+			data[i] = strconv.Itoa(duration)
+		}
+
+		fmt.Fprintf(buf, "# \nname: %v\ntype: raw\nfrequency: %d\nduty_cycle: 0.330000\ndata: %v\n", button, frequency, strings.Join(data, " "))
+	}
+
+	return buf.Flush()
+}
@@ -0,0 +1,183 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const flipperIR = `Filetype: IR signals file
+Version: 1
+#
+name: Power
+type: parsed
+protocol: NEC
+address: 04 00 00 00
+command: 08 00 00 00
+#
+name: Vol_up
+type: parsed
+protocol: NECext
+address: 04 F1 00 00
+command: 02 FD 00 00
+#
+name: Source
+type: parsed
+protocol: Samsung32
+address: 07 00 00 00
+command: 01 00 00 00
+#
+name: Mute
+type: parsed
+protocol: RC5
+address: 05 00 00 00
+command: 0C 00 00 00
+#
+name: Input
+type: parsed
+protocol: SIRC20
+address: 3A 01 00 00
+command: 15 00 00 00
+#
+name: Fan
+type: raw
+frequency: 36000
+duty_cycle: 0.330000
+data: 1300 400 1300 400 400
+`
+
+func TestParseFlipperIR(t *testing.T) {
+	signals, err := ParseFlipperIR(strings.NewReader(flipperIR))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(signals) != 6 {
+		t.Fatalf("got %d signals, want 6", len(signals))
+	}
+	if vol := signals[1]; vol.Protocol != "NECext" || vol.Address != 0xf104 || vol.Command != 0xfd02 {
+		t.Errorf("got signal %+v", vol)
+	}
+	if fan := signals[5]; fan.Type != "raw" || fan.Frequency != 36000 || fan.DutyCycle != 0.33 || len(fan.Data) != 5 {
+		t.Errorf("got signal %+v", fan)
+	}
+}
+
+func TestParseFlipperIRInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no header":      "name: Power\ntype: raw\ndata: 100 100 100\n",
+		"filetype":       "Filetype: Flipper SubGhz RAW File\n",
+		"without name":   "Filetype: IR signals file\ntype: raw\n",
+		"invalid line":   "Filetype: IR signals file\nname: Power\ntype raw\n",
+		"invalid data":   "Filetype: IR signals file\nname: Power\ntype: raw\ndata: 100 -5\n",
+		"invalid number": "Filetype: IR signals file\nname: Power\ntype: parsed\naddress: 04 00 00 00 00\n",
+	}
+
+	for name, file := range tests {
+		if _, err := ParseFlipperIR(strings.NewReader(file)); err == nil {
+			t.Errorf("%v: no error", name)
+		}
+	}
+}
+
+// nearPulses reports if the durations of a packet differ by less than two Broadlink ticks from want
+func nearPulses(t *testing.T, packet []byte, want []int) bool {
+	t.Helper()
+	pulses, err := irPulses(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pulses) != len(want) {
+		return false
+	}
+	for i := range want {
+		if pulses[i] < want[i]-66 || pulses[i] > want[i]+66 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestImportFlipperIR(t *testing.T) {
+	lib, err := ImportFlipperIR(strings.NewReader(flipperIR), "tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		pulses    []int
+		repeat    uint8
+		frequency uint
+	}{
+		{"tv/Power", encodeNEC(0x04, 0x08, false, 0), 0, 38000},
+		{"tv/Vol_up", encodeNEC(0xf104, 0xfd02, true, 0), 0, 38000},
+		{"tv/Source", encodeSamsung(0x07, 0x01), 0, 38000},
+		{"tv/Mute", encodeRC5(0x05, 0x0c, false), 0, 36000},
+		// the address of SIRC20 holds the extended device field in bits 5-12, Sony devices get the code three times
+		{"tv/Input", encodeSIRC(0x1a, 0x15, 0x09, 20), 2, 40000},
+		{"tv/Fan", []int{1300, 400, 1300, 400, 400, defaultGap}, 0, 36000},
+	}
+
+	for _, test := range tests {
+		code, err := lib.Get(test.name)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		packet, _ := code.Packet()
+		if !nearPulses(t, packet, test.pulses) || packet[1] != test.repeat || code.Frequency != test.frequency {
+			t.Errorf("%v: got %x at %d Hz, want %v repeated %d times at %d Hz", test.name, packet, code.Frequency, test.pulses, test.repeat, test.frequency)
+		}
+	}
+
+	unsupported := "Filetype: IR signals file\nname: Power\ntype: parsed\nprotocol: RCA\n"
+	if _, err := ImportFlipperIR(strings.NewReader(unsupported), "tv"); err == nil {
+		t.Error("no error for an unsupported protocol")
+	}
+}
+
+func TestExportFlipperIR(t *testing.T) {
+	lib, err := ImportFlipperIR(strings.NewReader(flipperIR), "tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportFlipperIR(&buf, lib.Remotes["tv"]); err != nil {
+		t.Fatal(err)
+	}
+
+	signals, err := ParseFlipperIR(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signals) != 6 {
+		t.Fatalf("got %d signals, want 6", len(signals))
+	}
+
+	// signals are sorted by name and end with a pulse, the repeat count becomes repeated frames
+	input := signals[1]
+	frame := encodeSIRC(0x1a, 0x15, 0x09, 20)
+	if input.Name != "Input" || input.Type != "raw" || input.Frequency != 40000 || len(input.Data) != 3*len(frame)-1 {
+		t.Errorf("got signal %v %v at %d Hz with %d durations", input.Name, input.Type, input.Frequency, len(input.Data))
+	}
+
+	exported, err := ImportFlipperIR(&buf, "tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mute, _ := exported.Get("tv/Mute")
+	packet, _ := mute.Packet()
+	rc5 := encodeRC5(0x05, 0x0c, false)
+	if !nearPulses(t, packet, append(rc5[:len(rc5)-1], defaultGap)) || mute.Frequency != 36000 {
+		t.Errorf("got mute %x at %d Hz", packet, mute.Frequency)
+	}
+}
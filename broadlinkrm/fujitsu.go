@@ -0,0 +1,48 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Fujitsu remote timings in µs
+const (
+	fujitsuHeaderPulse = 3324
+	fujitsuHeaderSpace = 1574
+	fujitsuBitPulse    = 448
+	fujitsuOneSpace    = 1182
+	fujitsuZeroSpace   = 390
+	fujitsuGap         = 8100
+)
+
+// fujitsuModel are the codes of Fujitsu AR-RAx remotes, states are send with 16 bytes, power off with 7 bytes
+var fujitsuModel = acModel{
+	names:          []string{"fujitsu", "fujitsu-ar-rax"},
+	minTemperature: 16,
+	maxTemperature: 30,
+	modes:          map[string]uint32{"auto": 0, "cool": 1, "dry": 2, "fan": 3, "heat": 4},
+	fans:           map[string]uint32{"auto": 0, "high": 1, "medium": 2, "low": 3, "quiet": 4},
+	encode:         encodeFujitsu,
+}
+
+func encodeFujitsu(state ACState, mode uint32, fan uint32) []int {
+	data := []byte{0x14, 0x63, 0x00, 0x10, 0x10, 0x02, 0xfd}
+
+	if state.Power {
+		data = []byte{0x14, 0x63, 0x00, 0x10, 0x10, 0xfe, 0x09, 0x30, 0, 0, 0, 0, 0, 0, 0x20, 0}
+		// the power bit switches the air conditioner on
+		data[8] = byte(state.Temperature-16)<<4 | 1
+		data[9] = byte(mode)
+		data[10] = byte(fan)
+		if state.Swing {
+			data[10] |= 1 << 4
+		}
+		data[15] = -sumBytes(data[7:15])
+	}
+
+	var train pulseTrain
+	train.add(true, fujitsuHeaderPulse)
+	train.add(false, fujitsuHeaderSpace)
+	train.spaceBytes(data, fujitsuBitPulse, fujitsuOneSpace, fujitsuZeroSpace)
+	train.add(true, fujitsuBitPulse)
+	train.add(false, fujitsuGap)
+	return train
+}
@@ -0,0 +1,33 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeFujitsu(t *testing.T) {
+	// power off is a short code of 7 bytes
+	pulses := encodeFujitsu(ACState{Mode: "cool", Temperature: 24, Fan: "auto"}, 1, 0)
+	if len(pulses) != 2+7*16+2 {
+		t.Fatalf("got %d durations", len(pulses))
+	}
+	if data := readSpaceBytes(t, pulses[2:], 7, fujitsuBitPulse, fujitsuOneSpace, fujitsuZeroSpace); !bytes.Equal(data, []byte{0x14, 0x63, 0x00, 0x10, 0x10, 0x02, 0xfd}) {
+		t.Errorf("got %x", data)
+	}
+
+	pulses = encodeFujitsu(ACState{Power: true, Mode: "cool", Temperature: 24, Fan: "low", Swing: true}, 1, 3)
+	data := readSpaceBytes(t, pulses[2:], 16, fujitsuBitPulse, fujitsuOneSpace, fujitsuZeroSpace)
+	want := []byte{0x14, 0x63, 0x00, 0x10, 0x10, 0xfe, 0x09, 0x30, 0x81, 0x01, 0x13, 0x00, 0x00, 0x00, 0x20, 0x00}
+	want[15] = -sumBytes(want[7:15])
+	if !bytes.Equal(data, want) {
+		t.Errorf("got %x, want %x", data, want)
+	}
+
+	// the bytes from the eighth on sum up to 0 with the checksum
+	if sum := sumBytes(data[7:]); sum != 0 {
+		t.Errorf("got checksum sum 0x%02x", sum)
+	}
+}
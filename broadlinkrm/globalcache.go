@@ -0,0 +1,212 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// gcMaxRepeat is the highest repeat count a Global Caché device accepts
+const gcMaxRepeat = 50
+
+// ConvertGlobalCache2Broadlink converts a Global Caché sendir command to broadlink code.
+//
+// sendir - "sendir,1:1,1,38000,1,1,343,171,21,..." or only the fields starting with the frequency, compressed codes are supported
+// If the whole code is repeated the repeat count is stored in the repeat byte of the broadlink code,
+// a repeated part starting at the repeat offset is appended to the pulses.
+func ConvertGlobalCache2Broadlink(sendir string) ([]byte, error) {
+	fields, err := gcFields(sendir)
+	if err != nil {
+		return nil, err
+	}
+
+	var header [3]uint64
+	for i := range header {
+		value, err := strconv.ParseUint(fields[i], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sendir field %q", fields[i])
+		}
+		header[i] = value
+	}
+
+	frequency, repeat, offset := header[0], int(header[1]), int(header[2])
+	if frequency < 15000 || frequency > 500000 {
+		return nil, fmt.Errorf("invalid frequency %d", frequency)
+	}
+
+	cycles, err := gcDurations(fields[3:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cycles) == 0 || len(cycles)%2 != 0 {
+		return nil, fmt.Errorf("sendir needs on/off pairs, got %d durations", len(cycles))
+	}
+
+	if repeat < 1 || repeat > gcMaxRepeat {
+		return nil, fmt.Errorf("invalid repeat count %d", repeat)
+	}
+
+	if offset < 1 || offset%2 == 0 || offset > len(cycles) {
+		return nil, fmt.Errorf("invalid repeat offset %d", offset)
+	}
+
+	lircCode := make([]int, len(cycles))
+	for i, count := range cycles {
+		lircCode[i] = int(math.Round(float64(count) * 1000000 / float64(frequency)))
+	}
+
+	repeatByte := byte(repeat - 1)
+	if offset > 1 {
+		for i := 1; i < repeat; i++ {
+			lircCode = append(lircCode, lircCode[offset-1:len(cycles)]...)
+		}
+		repeatByte = 0
+	}
+
+	broadlinkCode, err := lirc2broadlink(lircCode)
+	if err != nil {
+		return nil, err
+	}
+	broadlinkCode[1] = repeatByte
+
+	return broadlinkCode, nil
+}
+
+// GlobalCacheFrequency returns the carrier frequency in Hz of a Global Caché sendir command, 0 if the command is invalid
+func GlobalCacheFrequency(sendir string) uint {
+	fields, err := gcFields(sendir)
+	if err != nil {
+		return 0
+	}
+
+	frequency, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	return uint(frequency)
+}
+
+// gcFields returns the fields of a sendir command starting with the frequency
+func gcFields(sendir string) ([]string, error) {
+	fields := strings.Split(strings.Replace(strings.TrimSpace(sendir), " ", "", -1), ",")
+	if strings.EqualFold(fields[0], "sendir") {
+		if len(fields) < 3 {
+			return nil, errors.New("sendir command too short")
+		}
+		fields = fields[3:]
+	}
+
+	if len(fields) < 5 {
+		return nil, errors.New("sendir command too short")
+	}
+
+	return fields, nil
+}
+
+// ConvertBroadlink2GlobalCache converts broadlink code to a Global Caché sendir command
+//
+// frequency - frequency in Hz of the IR carrier, 0 uses the frequency of the IR protocol of the code or 38000 Hz like the Flipper export
+func ConvertBroadlink2GlobalCache(broadlinkByte []byte, frequency uint) (string, error) {
+	lircCode, err := irPulses(broadlinkByte)
+	if err != nil {
+		return "", err
+	}
+
+	if frequency == 0 {
+		frequency = InferFrequency(broadlinkByte)
+	}
+	if frequency == 0 {
+		frequency = 38000
+	}
+
+	if len(lircCode)%2 != 0 {
+		lircCode = append(lircCode, defaultGap)
+	}
+
+	repeat := int(broadlinkByte[1]) + 1
+	if repeat > gcMaxRepeat {
+		repeat = gcMaxRepeat
+	}
+
+	fields := []string{"sendir", "1:1", "1", strconv.Itoa(int(frequency)), strconv.Itoa(repeat), "1"}
+	for _, duration := range lircCode {
+		count := int(math.Round(float64(duration) * float64(frequency) / 1000000))
+		if count < 1 {
+			count = 1
+		}
+		fields = append(fields, strconv.Itoa(count))
+	}
+
+	return strings.Join(fields, ","), nil
+}
+
+// gcDurations parses the on/off durations of a sendir command.
+// In compressed codes the letters A-O stand for the first 15 distinct on/off pairs, e.g. "21,21A" or "AB".
+func gcDurations(fields []string) (cycles []int, err error) {
+	var pairs [][2]int
+	number := ""
+
+	store := func() {
+		if len(cycles)%2 != 0 || len(cycles) == 0 || len(pairs) >= 15 {
+			return
+		}
+
+		pair := [2]int{cycles[len(cycles)-2], cycles[len(cycles)-1]}
+		for _, known := range pairs {
+			if known == pair {
+				return
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+
+	flush := func() error {
+		if len(number) == 0 {
+			return nil
+		}
+
+		value, err := strconv.Atoi(number)
+		if err != nil || value < 1 {
+			return fmt.Errorf("invalid duration %q", number)
+		}
+
+		cycles = append(cycles, value)
+		number = ""
+		store()
+		return nil
+	}
+
+	for _, field := range fields {
+		for _, char := range field {
+			switch {
+			case char >= '0' && char <= '9':
+				number += string(char)
+			case char >= 'A' && char <= 'O':
+				if err = flush(); err != nil {
+					return nil, err
+				}
+
+				index := int(char - 'A')
+				if index >= len(pairs) || len(cycles)%2 != 0 {
+					return nil, fmt.Errorf("invalid compressed pair %c", char)
+				}
+				cycles = append(cycles, pairs[index][0], pairs[index][1])
+			default:
+				return nil, fmt.Errorf("invalid character %q in sendir durations", char)
+			}
+		}
+
+		if err = flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cycles, nil
+}
@@ -0,0 +1,115 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertGlobalCache2Broadlink(t *testing.T) {
+	tests := []struct {
+		sendir string
+		pulses []int
+		repeat uint8
+	}{
+		{"sendir,1:1,1,40000,1,1,96,24,48,24,24,960", []int{2400, 600, 1200, 600, 600, 24000}, 0},
+		{"40000,3,1,96,24,48,24,24,960", []int{2400, 600, 1200, 600, 600, 24000}, 2},
+		// compressed: A and B stand for the first two distinct pairs
+		{"sendir,1:1,1,40000,1,1,96,24,48,24A,B,24,960", []int{2400, 600, 1200, 600, 2400, 600, 1200, 600, 600, 24000}, 0},
+		// the part starting at the repeat offset is appended for each repeat
+		{"sendir,1:1,1,40000,2,3,96,24,48,960", []int{2400, 600, 1200, 24000, 1200, 24000}, 0},
+	}
+
+	for _, test := range tests {
+		code, err := ConvertGlobalCache2Broadlink(test.sendir)
+		if err != nil {
+			t.Errorf("%v: %v", test.sendir, err)
+			continue
+		}
+
+		packet, _ := ParsePacket(code)
+		if !matchPulses(packet.Pulses, test.pulses) || packet.Repeat != test.repeat {
+			t.Errorf("%v: got %v with repeat %d, want %v with repeat %d", test.sendir, packet.Pulses, packet.Repeat, test.pulses, test.repeat)
+		}
+	}
+}
+
+// matchPulses reports if the durations match within the tolerance of the decoders, e.g. after rounding to Broadlink ticks
+func matchPulses(pulses []int, want []int) bool {
+	if len(pulses) != len(want) {
+		return false
+	}
+
+	for i := range pulses {
+		if !matchDuration(pulses[i], want[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestConvertGlobalCache2BroadlinkInvalid(t *testing.T) {
+	tests := []string{
+		"sendir,1:1",
+		"sendir,1:1,1,40000,1,1,96,24,48",
+		"sendir,1:1,1,1000,1,1,96,24,48,24",
+		"sendir,1:1,1,40000,0,1,96,24,48,24",
+		"sendir,1:1,1,40000,1,2,96,24,48,24",
+		"sendir,1:1,1,40000,1,1,96,24,A,24",
+		"sendir,1:1,1,40000,1,1,96,24,48,x",
+	}
+
+	for _, sendir := range tests {
+		if _, err := ConvertGlobalCache2Broadlink(sendir); err == nil {
+			t.Errorf("%v: no error", sendir)
+		}
+	}
+}
+
+func TestGlobalCacheRoundTrip(t *testing.T) {
+	packet, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c, Repeats: 2})
+	sendir, err := ConvertBroadlink2GlobalCache(packet, 36000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(sendir, "sendir,1:1,1,36000,3,1,32,32,64,") || GlobalCacheFrequency(sendir) != 36000 {
+		t.Errorf("got %v", sendir)
+	}
+
+	converted, err := ConvertGlobalCache2Broadlink(sendir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := DecodeIR(converted); err != nil || code.String() != "RC5 addr=0x05 cmd=0x0c" || converted[1] != 2 {
+		t.Errorf("got %v with repeat %d (%v)", code, converted[1], err)
+	}
+}
+
+func TestConvertBroadlink2GlobalCacheFrequency(t *testing.T) {
+	rc5, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c})
+	raw, _ := Packet{Kind: PacketIR, Pulses: []int{1000, 1000, 1000, 20000}}.Marshal()
+
+	tests := []struct {
+		packet []byte
+		want   uint
+	}{
+		{rc5, 36000},
+		{raw, 38000},
+	}
+
+	for _, test := range tests {
+		sendir, err := ConvertBroadlink2GlobalCache(test.packet, 0)
+		if err != nil || GlobalCacheFrequency(sendir) != test.want {
+			t.Errorf("got %v (%v), want frequency %d", sendir, err, test.want)
+		}
+	}
+
+	rf, _ := Packet{Kind: PacketRF433, Pulses: []int{300, 900}}.Marshal()
+	if _, err := ConvertBroadlink2GlobalCache(rf, 38000); err == nil {
+		t.Error("no error for an RF packet")
+	}
+}
@@ -0,0 +1,63 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Gree remote timings in µs
+const (
+	greeHeaderPulse  = 9000
+	greeHeaderSpace  = 4500
+	greeBitPulse     = 620
+	greeOneSpace     = 1600
+	greeZeroSpace    = 540
+	greeMessageSpace = 19980
+)
+
+// greeModel are the 64 bit codes of Gree YAW1F remotes, send as two blocks of 4 bytes joined by the 3 bit footer 0b010
+var greeModel = acModel{
+	names:          []string{"gree", "gree-yaw1f"},
+	minTemperature: 16,
+	maxTemperature: 30,
+	modes:          map[string]uint32{"auto": 0, "cool": 1, "dry": 2, "fan": 3, "heat": 4},
+	fans:           map[string]uint32{"auto": 0, "low": 1, "medium": 2, "high": 3},
+	encode:         encodeGree,
+}
+
+func encodeGree(state ACState, mode uint32, fan uint32) []int {
+	// light on and the fixed bits of the YAW1F
+	data := []byte{0x00, 0x00, 0x20, 0x50, 0x00, 0x20, 0x00, 0x00}
+
+	data[0] = byte(mode) | byte(fan)<<4
+	if state.Power {
+		// the YAW1F repeats the power bit in the third byte
+		data[0] |= 0x08
+		data[2] |= 0x40
+	}
+	data[1] = byte(state.Temperature - 16)
+	if state.Swing {
+		data[0] |= 0x40
+		data[4] = 1
+	}
+
+	// the checksum is the sum of the lower nibbles of the first block and the upper nibbles of the second
+	sum := byte(10)
+	for i := 0; i < 4; i++ {
+		sum += data[i] & 0x0f
+	}
+	for i := 4; i < 7; i++ {
+		sum += data[i] >> 4
+	}
+	data[7] = sum<<4 | data[7]&0x0f
+
+	var train pulseTrain
+	train.add(true, greeHeaderPulse)
+	train.add(false, greeHeaderSpace)
+	train.spaceBytes(data[:4], greeBitPulse, greeOneSpace, greeZeroSpace)
+	train.spaceBits(0x2, 3, greeBitPulse, greeOneSpace, greeZeroSpace)
+	train.add(true, greeBitPulse)
+	train.add(false, greeMessageSpace)
+	train.spaceBytes(data[4:], greeBitPulse, greeOneSpace, greeZeroSpace)
+	train.add(true, greeBitPulse)
+	train.add(false, greeMessageSpace)
+	return train
+}
@@ -0,0 +1,29 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeGree(t *testing.T) {
+	pulses := encodeGree(ACState{Power: true, Mode: "cool", Temperature: 24, Fan: "auto"}, 1, 0)
+
+	// two blocks of 4 bytes, the first followed by the footer 0b010 and a message space
+	first := readSpaceBytes(t, pulses[2:], 4, greeBitPulse, greeOneSpace, greeZeroSpace)
+	footer, ok := readSpaceBits(pulses[66:], 3, greeBitPulse, greeOneSpace, greeZeroSpace)
+	second := readSpaceBytes(t, pulses[74:], 4, greeBitPulse, greeOneSpace, greeZeroSpace)
+
+	if want := []byte{0x09, 0x08, 0x60, 0x50}; !bytes.Equal(first, want) {
+		t.Errorf("got first block %x, want %x", first, want)
+	}
+	if !ok || footer != 2 || !matchDuration(pulses[73], greeMessageSpace) {
+		t.Errorf("got footer %b and space %d", footer, pulses[73])
+	}
+	// checksum 10 + 9 + 8 + 2 = 0x1d, its lower nibble is the upper nibble of the last byte
+	if want := []byte{0x00, 0x20, 0x00, 0xd0}; !bytes.Equal(second, want) {
+		t.Errorf("got second block %x, want %x", second, want)
+	}
+}
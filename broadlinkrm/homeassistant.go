@@ -0,0 +1,156 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DecodeBase64Code decodes a base64 encoded code as used by Home Assistant and SmartIR, a "b64:" prefix is ignored
+func DecodeBase64Code(code string) ([]byte, error) {
+	code = strings.TrimPrefix(strings.TrimSpace(code), "b64:")
+	data, err := base64.StdEncoding.DecodeString(code)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(code)
+	}
+
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid base64 code %q", code)
+	}
+
+	return data, nil
+}
+
+// ImportHomeAssistantCodes reads the codes of a Home Assistant storage file .storage/broadlink_remote_*_codes into a code library.
+// The devices of the file become remotes, their commands buttons. The alternative codes of a toggle command are stored as "command_2", "command_3", ...
+func ImportHomeAssistantCodes(r io.Reader) (*CodeLibrary, error) {
+	var storage struct {
+		Data map[string]map[string]json.RawMessage `json:"data"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&storage); err != nil {
+		return nil, err
+	}
+
+	lib := NewCodeLibrary()
+	for device, commands := range storage.Data {
+		for command, raw := range commands {
+			var codes []string
+			if err := json.Unmarshal(raw, &codes); err != nil {
+				var code string
+				if err = json.Unmarshal(raw, &code); err != nil {
+					return nil, fmt.Errorf("%v/%v: invalid code", device, command)
+				}
+				codes = []string{code}
+			}
+
+			for i, data := range codes {
+				packet, err := DecodeBase64Code(data)
+				if err != nil {
+					return nil, fmt.Errorf("%v/%v: %v", device, command, err)
+				}
+
+				name := device + "/" + command
+				if i > 0 {
+					name = fmt.Sprintf("%v_%d", name, i+1)
+				}
+
+				if err = lib.Set(name, NewBroadlinkCode(packet)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return lib, nil
+}
+
+// ImportSmartIR reads the commands of a SmartIR device file into a code library, all commands become buttons of the given remote.
+// Nested commands are named by their path, e.g. "cool_low_21" for mode cool, fan speed low and 21 degrees.
+func ImportSmartIR(r io.Reader, remote string) (*CodeLibrary, error) {
+	var device struct {
+		Manufacturer        string                 `json:"manufacturer"`
+		SupportedController string                 `json:"supportedController"`
+		CommandsEncoding    string                 `json:"commandsEncoding"`
+		Commands            map[string]interface{} `json:"commands"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&device); err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(device.CommandsEncoding)
+	switch {
+	case encoding == "pronto":
+	case encoding == "base64" && strings.EqualFold(device.SupportedController, "Broadlink"):
+	default:
+		return nil, fmt.Errorf("unsupported SmartIR controller %v with encoding %v", device.SupportedController, device.CommandsEncoding)
+	}
+
+	lib := NewCodeLibrary()
+	var add func(name string, value interface{}) error
+	add = func(name string, value interface{}) error {
+		switch value := value.(type) {
+		case string:
+			var code Code
+			if encoding == "pronto" {
+				data, err := hex.DecodeString(strings.Replace(value, " ", "", -1))
+				if err != nil {
+					return fmt.Errorf("command %v: invalid pronto code", name)
+				}
+				if code, err = NewCode(FormatPronto, data); err != nil {
+					return fmt.Errorf("command %v: %v", name, err)
+				}
+			} else {
+				packet, err := DecodeBase64Code(value)
+				if err != nil {
+					return fmt.Errorf("command %v: %v", name, err)
+				}
+				code = NewBroadlinkCode(packet)
+			}
+
+			return lib.Set(remote+"/"+name, code)
+		case map[string]interface{}:
+			var keys []string
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				subName := key
+				if len(name) != 0 {
+					subName = name + "_" + key
+				}
+
+				if err := add(subName, value[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		case nil:
+			return nil
+		}
+
+		return fmt.Errorf("command %v: unsupported value", name)
+	}
+
+	if err := add("", device.Commands); err != nil {
+		return nil, err
+	}
+
+	if description := strings.TrimSpace(device.Manufacturer); len(description) != 0 {
+		if r, found := lib.Remotes[remote]; found {
+			r.Description = description
+		}
+	}
+
+	return lib, nil
+}
@@ -0,0 +1,124 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPacket returns a short IR packet in Broadlink format told apart by its first pulse
+func testPacket(pulse byte) []byte {
+	return []byte{0x26, 0x00, 0x02, 0x00, pulse, 0x12, 0x0d, 0x05}
+}
+
+// libraryPulses returns the first pulse of all codes in the library by name
+func libraryPulses(t *testing.T, lib *CodeLibrary) map[string]byte {
+	t.Helper()
+	pulses := map[string]byte{}
+	for _, name := range lib.Names() {
+		code, _ := lib.Get(name)
+		packet, err := code.Packet()
+		if err != nil || len(packet) != 8 {
+			t.Fatalf("%v: got %x (%v)", name, packet, err)
+		}
+		pulses[name] = packet[4]
+	}
+
+	return pulses
+}
+
+func TestDecodeBase64Code(t *testing.T) {
+	tests := map[string][]byte{
+		"JgAEAA==":     {0x26, 0x00, 0x04, 0x00},
+		"b64:JgAEAA==": {0x26, 0x00, 0x04, 0x00},
+		" JgAEAA ":     {0x26, 0x00, 0x04, 0x00},
+	}
+
+	for code, want := range tests {
+		if data, err := DecodeBase64Code(code); err != nil || !bytes.Equal(data, want) {
+			t.Errorf("%q: got %x (%v), want %x", code, data, err, want)
+		}
+	}
+
+	for _, code := range []string{"", "Jg!A", "b64:"} {
+		if _, err := DecodeBase64Code(code); err == nil {
+			t.Errorf("%q: no error", code)
+		}
+	}
+}
+
+func TestImportHomeAssistantCodes(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	storage := fmt.Sprintf(`{"version": 1, "key": "broadlink_remote_34ea34010203_codes", "data": {
+		"tv": {"power": %q, "mute": [%q, %q]},
+		"avr": {"hdmi1": %q}
+	}}`, b64(testPacket(0x08)), b64(testPacket(0x09)), b64(testPacket(0x0a)), "b64:"+b64(testPacket(0x0b)))
+
+	lib, err := ImportHomeAssistantCodes(strings.NewReader(storage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the alternative codes of a toggle command get numbered names
+	want := map[string]byte{"tv/power": 0x08, "tv/mute": 0x09, "tv/mute_2": 0x0a, "avr/hdmi1": 0x0b}
+	if pulses := libraryPulses(t, lib); !reflect.DeepEqual(pulses, want) {
+		t.Errorf("got %v, want %v", pulses, want)
+	}
+
+	tests := map[string]string{
+		"json":   `{"data": `,
+		"code":   `{"data": {"tv": {"power": 1}}}`,
+		"base64": `{"data": {"tv": {"power": "Jg!A"}}}`,
+	}
+	for name, storage := range tests {
+		if _, err := ImportHomeAssistantCodes(strings.NewReader(storage)); err == nil {
+			t.Errorf("%v: no error", name)
+		}
+	}
+}
+
+func TestImportSmartIR(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	device := fmt.Sprintf(`{"manufacturer": "Daikin", "supportedController": "Broadlink", "commandsEncoding": "Base64",
+		"commands": {"off": %q, "cool": {"low": {"21": %q, "22": %q}}, "fan_only": null}}`,
+		b64(testPacket(0x01)), b64(testPacket(0x21)), b64(testPacket(0x22)))
+
+	lib, err := ImportSmartIR(strings.NewReader(device), "ac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]byte{"ac/off": 0x01, "ac/cool_low_21": 0x21, "ac/cool_low_22": 0x22}
+	if pulses := libraryPulses(t, lib); !reflect.DeepEqual(pulses, want) {
+		t.Errorf("got %v, want %v", pulses, want)
+	}
+	if lib.Remotes["ac"].Description != "Daikin" {
+		t.Errorf("got description %q", lib.Remotes["ac"].Description)
+	}
+
+	pronto := `{"supportedController": "Broadlink", "commandsEncoding": "Pronto", "commands": {"power": "0000 006D 0001 0000 0157 00AB"}}`
+	lib, err = ImportSmartIR(strings.NewReader(pronto), "tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := lib.Get("tv/power"); code == nil || code.Format != FormatPronto || code.Data != "0000006d00010000015700ab" {
+		t.Errorf("got pronto code %+v", code)
+	}
+
+	tests := map[string]string{
+		"controller": `{"supportedController": "Xiaomi", "commandsEncoding": "Base64", "commands": {}}`,
+		"value":      `{"supportedController": "Broadlink", "commandsEncoding": "Base64", "commands": {"off": 1}}`,
+		"pronto":     `{"supportedController": "Broadlink", "commandsEncoding": "Pronto", "commands": {"off": "00zz"}}`,
+	}
+	for name, device := range tests {
+		if _, err := ImportSmartIR(strings.NewReader(device), "ac"); err == nil {
+			t.Errorf("%v: no error", name)
+		}
+	}
+}
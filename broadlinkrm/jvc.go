@@ -0,0 +1,73 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// JVC timings in µs, code and repeat frames start every jvcFramePeriod
+const (
+	jvcHeaderPulse = 8400
+	jvcHeaderSpace = 4200
+	jvcBitPulse    = 525
+	jvcOneSpace    = 1575
+	jvcZeroSpace   = 525
+	jvcFramePeriod = 60000
+)
+
+// jvcProtocol sends repeats as frames without header
+var jvcProtocol = IRProtocol{
+	Names:  []string{"JVC"},
+	Decode: decodeJVC,
+	Encode: func(code IRCode) ([]int, int) {
+		return encodeJVC(code.Address, code.Command, code.Repeats), 0
+	},
+	RepeatFrames: true,
+	Frequency:    38000,
+}
+
+// encodeJVC encodes a JVC code with 8 bit address and 8 bit command followed by repeat frames
+func encodeJVC(address uint32, command uint32, repeats int) []int {
+	var train pulseTrain
+	train.add(true, jvcHeaderPulse)
+	train.add(false, jvcHeaderSpace)
+
+	for i := 0; i <= repeats; i++ {
+		start := train.length()
+		if i == 0 {
+			start = 0
+		}
+
+		train.spaceBits(uint64(address&0xff|(command&0xff)<<8), 16, jvcBitPulse, jvcOneSpace, jvcZeroSpace)
+		train.add(true, jvcBitPulse)
+		train.pad(start + jvcFramePeriod)
+	}
+
+	return train
+}
+
+// decodeJVC decodes a JVC code with the following repeat frames
+func decodeJVC(pulses []int) (code IRCode, ok bool) {
+	if len(pulses) < 35 || !matchDuration(pulses[0], jvcHeaderPulse) || !matchDuration(pulses[1], jvcHeaderSpace) {
+		return code, false
+	}
+
+	value, ok := readSpaceBits(pulses[2:], 16, jvcBitPulse, jvcOneSpace, jvcZeroSpace)
+	if !ok || !matchDuration(pulses[34], jvcBitPulse) {
+		return code, false
+	}
+
+	code.Protocol = "JVC"
+	code.Address, code.Command = uint32(value&0xff), uint32(value>>8)
+	code.Valid = true
+
+	// repeat frames start after the gap following the stop bit
+	for next := pulses[35:]; len(next) > 33; next = next[34:] {
+		repeat, ok := readSpaceBits(next[1:], 16, jvcBitPulse, jvcOneSpace, jvcZeroSpace)
+		if !ok || repeat != value || !matchDuration(next[33], jvcBitPulse) {
+			break
+		}
+
+		code.Repeats++
+	}
+
+	return code, true
+}
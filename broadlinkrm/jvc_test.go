@@ -0,0 +1,33 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+func TestEncodeJVC(t *testing.T) {
+	// repeat frames have no header, every frame starts 60 ms after the previous one
+	pulses := encodeJVC(0x03, 0x17, 2)
+	if len(pulses) != 36+2*34 || pulseTrain(pulses).length() != 3*jvcFramePeriod {
+		t.Errorf("got %d durations lasting %d µs", len(pulses), pulseTrain(pulses).length())
+	}
+
+	if value, ok := readSpaceBits(pulses[36:], 16, jvcBitPulse, jvcOneSpace, jvcZeroSpace); !ok || value != 0x1703 {
+		t.Errorf("got repeat frame 0x%04x", value)
+	}
+}
+
+func TestDecodeJVC(t *testing.T) {
+	if code, ok := decodeJVC(encodeJVC(0x03, 0x17, 2)); !ok || code.String() != "JVC addr=0x03 cmd=0x17" || code.Repeats != 2 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+
+	// a repeat frame of another code ends the repeats
+	other := encodeJVC(0x03, 0x18, 1)
+	pulses := append(encodeJVC(0x03, 0x17, 0), other[36:]...)
+	if code, ok := decodeJVC(pulses); !ok || code.Repeats != 0 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+}
@@ -0,0 +1,86 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Kaseikyo timings in µs
+const (
+	kaseikyoUnit        = 432
+	kaseikyoHeaderPulse = 8 * kaseikyoUnit
+	kaseikyoHeaderSpace = 4 * kaseikyoUnit
+	kaseikyoOneSpace    = 3 * kaseikyoUnit
+	kaseikyoFramePeriod = 130000
+	// kaseikyoFrameLength is the number of durations of a frame including the gap
+	kaseikyoFrameLength = 100
+	// panasonicVendor is the vendor of the Kaseikyo codes of Panasonic
+	panasonicVendor = 0x2002
+)
+
+// kaseikyoProtocol repeats the whole frame, codes of other vendors than Panasonic hold the vendor in the extended field
+var kaseikyoProtocol = IRProtocol{
+	Names:  []string{"Panasonic", "Kaseikyo"},
+	Decode: decodeKaseikyo,
+	Encode: func(code IRCode) ([]int, int) {
+		if code.Protocol == "Panasonic" {
+			code.Extended = panasonicVendor
+		}
+		return encodeKaseikyo(code.Extended, code.Address, code.Command), code.Repeats
+	},
+	Frequency: 37000,
+}
+
+// encodeKaseikyo encodes a 48 bit Kaseikyo code with 16 bit vendor, 4 bit vendor parity, 12 bit address, 8 bit command and 8 bit parity
+func encodeKaseikyo(vendor uint32, address uint32, command uint32) []int {
+	data := make([]byte, 6)
+	data[0], data[1] = byte(vendor), byte(vendor>>8)
+	data[2] = kaseikyoVendorParity(vendor) | byte(address<<4)
+	data[3] = byte(address >> 4)
+	data[4] = byte(command)
+	data[5] = data[2] ^ data[3] ^ data[4]
+
+	var train pulseTrain
+	train.add(true, kaseikyoHeaderPulse)
+	train.add(false, kaseikyoHeaderSpace)
+	for _, b := range data {
+		train.spaceBits(uint64(b), 8, kaseikyoUnit, kaseikyoOneSpace, kaseikyoUnit)
+	}
+	train.add(true, kaseikyoUnit)
+	train.pad(kaseikyoFramePeriod)
+	return train
+}
+
+// decodeKaseikyo decodes a Kaseikyo code, identical frames following the first are counted as repeats
+func decodeKaseikyo(pulses []int) (code IRCode, ok bool) {
+	if len(pulses) < kaseikyoFrameLength-1 || !matchDuration(pulses[0], kaseikyoHeaderPulse) || !matchDuration(pulses[1], kaseikyoHeaderSpace) {
+		return code, false
+	}
+
+	value, ok := readSpaceBits(pulses[2:], 48, kaseikyoUnit, kaseikyoOneSpace, kaseikyoUnit)
+	if !ok || !matchDuration(pulses[98], kaseikyoUnit) {
+		return code, false
+	}
+
+	data := make([]byte, 6)
+	for i := range data {
+		data[i] = byte(value >> (8 * uint(i)))
+	}
+
+	vendor := uint32(data[0]) | uint32(data[1])<<8
+	code.Protocol = "Kaseikyo"
+	code.Extended = vendor
+	if vendor == panasonicVendor {
+		code.Protocol, code.Extended = "Panasonic", 0
+	}
+
+	code.Address = uint32(data[2]>>4) | uint32(data[3])<<4
+	code.Command = uint32(data[4])
+	code.Valid = data[2]&0x0f == kaseikyoVendorParity(vendor) && data[5] == data[2]^data[3]^data[4]
+	code.Repeats = repeatedFrames(code, pulses, kaseikyoFrameLength, decodeKaseikyo)
+	return code, true
+}
+
+// kaseikyoVendorParity returns the xor of the four nibbles of the vendor
+func kaseikyoVendorParity(vendor uint32) byte {
+	parity := vendor ^ vendor>>8
+	return byte(parity^parity>>4) & 0x0f
+}
@@ -0,0 +1,46 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+func TestEncodeKaseikyo(t *testing.T) {
+	if parity := kaseikyoVendorParity(panasonicVendor); parity != 0 {
+		t.Errorf("got vendor parity %x of Panasonic, want 0", parity)
+	}
+
+	// power of Panasonic TVs: vendor 0x2002, address 0x008 after the vendor parity, command 0x3d and the parity byte
+	pulses := encodeKaseikyo(panasonicVendor, 0x008, 0x3d)
+	if value, ok := readSpaceBits(pulses[2:], 48, kaseikyoUnit, kaseikyoOneSpace, kaseikyoUnit); !ok || value != 0xbd3d00802002 {
+		t.Errorf("got 0x%012x", value)
+	}
+	if len(pulses) != kaseikyoFrameLength {
+		t.Errorf("got %d durations, want %d", len(pulses), kaseikyoFrameLength)
+	}
+}
+
+func TestDecodeKaseikyo(t *testing.T) {
+	tests := []struct {
+		pulses []int
+		want   string
+	}{
+		{encodeKaseikyo(panasonicVendor, 0x008, 0x3d), "Panasonic addr=0x08 cmd=0x3d"},
+		{encodeKaseikyo(0x5aaa, 0x123, 0x45), "Kaseikyo addr=0x123 cmd=0x45 ext=0x5aaa"},
+	}
+
+	for _, test := range tests {
+		if code, ok := decodeKaseikyo(test.pulses); !ok || code.String() != test.want {
+			t.Errorf("got %v, want %v", code, test.want)
+		}
+	}
+
+	// a flipped command bit breaks the parity byte
+	pulses := encodeKaseikyo(panasonicVendor, 0x008, 0x3d)
+	pulses[2+2*32+1] = kaseikyoOneSpace + kaseikyoUnit - pulses[2+2*32+1]
+	if code, ok := decodeKaseikyo(pulses); !ok || code.Valid {
+		t.Errorf("got %v, want an invalid checksum", code)
+	}
+}
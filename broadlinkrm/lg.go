@@ -0,0 +1,64 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// LG air conditioner timings in µs
+const (
+	lgHeaderPulse = 8500
+	lgHeaderSpace = 4250
+	lgBitPulse    = 550
+	lgOneSpace    = 1600
+	lgZeroSpace   = 550
+	lgGap         = 39750
+	// lgSwingOn and lgSwingOff are the codes switching the vertical swing, it is not part of the state
+	lgSwingOn  = 0x8813149
+	lgSwingOff = 0x881315a
+)
+
+// lgModel are the 28 bit codes of LG remotes followed by the code of the swing
+var lgModel = acModel{
+	names:          []string{"lg"},
+	minTemperature: 16,
+	maxTemperature: 30,
+	modes:          map[string]uint32{"cool": 0, "dry": 1, "fan": 2, "auto": 3, "heat": 4},
+	fans:           map[string]uint32{"auto": 5, "low": 0, "medium": 2, "high": 4},
+	encode:         encodeLG,
+}
+
+func encodeLG(state ACState, mode uint32, fan uint32) []int {
+	// signature 0x88, power bits 0b11 for off
+	value := uint32(0x88<<20 | mode<<12 | uint32(state.Temperature-15)<<8 | fan<<4)
+	if !state.Power {
+		value = 0x88<<20 | 3<<18 | 5<<4
+	}
+
+	swing := uint32(lgSwingOff)
+	if state.Swing {
+		swing = lgSwingOn
+	}
+
+	var train pulseTrain
+	for _, code := range []uint32{lgChecksum(value), swing} {
+		train.add(true, lgHeaderPulse)
+		train.add(false, lgHeaderSpace)
+		// the bits are send MSB first
+		for bit := 27; bit >= 0; bit-- {
+			train.spaceBits(uint64(code>>uint(bit)&1), 1, lgBitPulse, lgOneSpace, lgZeroSpace)
+		}
+		train.add(true, lgBitPulse)
+		train.add(false, lgGap)
+	}
+
+	return train
+}
+
+// lgChecksum sets the lowest nibble to the sum of the other nibbles
+func lgChecksum(value uint32) uint32 {
+	var sum uint32
+	for shift := uint(4); shift < 28; shift += 4 {
+		sum += value >> shift & 0x0f
+	}
+
+	return value&^0x0f | sum&0x0f
+}
@@ -0,0 +1,58 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+// readLGCodes reads the 28 bit codes MSB first following each header
+func readLGCodes(pulses []int) (codes []uint32) {
+	for start := 0; start+58 < len(pulses); start += 60 {
+		var code uint32
+		for bit := 0; bit < 28; bit++ {
+			code <<= 1
+			if matchDuration(pulses[start+3+2*bit], lgOneSpace) {
+				code |= 1
+			}
+		}
+		codes = append(codes, code)
+	}
+
+	return codes
+}
+
+func TestLGChecksum(t *testing.T) {
+	tests := map[uint32]uint32{
+		0x88c0050: 0x88c0051, // off
+		0x8800950: 0x880095e, // cool 24°C fan auto
+		0x8813140: 0x8813149, // vertical swing on
+		0x8813150: 0x881315a, // vertical swing off
+	}
+
+	for value, want := range tests {
+		if got := lgChecksum(value); got != want {
+			t.Errorf("0x%07x: got 0x%07x, want 0x%07x", value, got, want)
+		}
+	}
+}
+
+func TestEncodeLG(t *testing.T) {
+	tests := []struct {
+		state ACState
+		mode  uint32
+		fan   uint32
+		want  [2]uint32
+	}{
+		{ACState{Power: true, Mode: "cool", Temperature: 24, Fan: "auto"}, 0, 5, [2]uint32{0x880095e, 0x881315a}},
+		{ACState{Mode: "cool", Temperature: 24, Fan: "auto", Swing: true}, 0, 5, [2]uint32{0x88c0051, 0x8813149}},
+	}
+
+	for _, test := range tests {
+		codes := readLGCodes(encodeLG(test.state, test.mode, test.fan))
+		if len(codes) != 2 || codes[0] != test.want[0] || codes[1] != test.want[1] {
+			t.Errorf("%+v: got %x, want %x", test.state, codes, test.want)
+		}
+	}
+}
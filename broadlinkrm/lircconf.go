@@ -0,0 +1,473 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// lircDefaultFrequency is the carrier frequency lircd assumes if a remote does not set one
+const lircDefaultFrequency = 38000
+
+// ErrLircEncoding is returned for codes of remotes with an encoding that is not supported, e.g. RCMM
+var ErrLircEncoding = errors.New("lircd encoding not supported")
+
+// LircRemote holds a remote definition of a lircd.conf file
+type LircRemote struct {
+	Name  string
+	Flags []string
+	// Bits of the data of a code without pre_data and post_data
+	Bits         uint
+	Header       [2]int
+	One          [2]int
+	Zero         [2]int
+	Pre          [2]int
+	Post         [2]int
+	Plead        int
+	Ptrail       int
+	PreDataBits  uint
+	PreData      uint64
+	PostDataBits uint
+	PostData     uint64
+	Gap          int
+	Frequency    uint
+	// RC6Mask marks the bits of double width of RC6 codes, counted from the last bit of pre_data, data and post_data
+	RC6Mask uint64
+	// MinRepeat is the number of times a code is repeated at least
+	MinRepeat uint
+	Codes     []LircCode
+}
+
+// LircCode is a button of a lircd.conf remote, either with the data of the codes section or the pulses of the raw_codes section
+type LircCode struct {
+	Name   string
+	Data   []uint64
+	Pulses []int
+}
+
+// ParseLircConf reads the remote definitions of a lircd.conf file
+func ParseLircConf(r io.Reader) (remotes []LircRemote, err error) {
+	var remote *LircRemote
+	var code *LircCode
+	section := ""
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		keyword := strings.ToLower(fields[0])
+		switch {
+		case keyword == "begin" && len(fields) == 2:
+			switch strings.ToLower(fields[1]) {
+			case "remote":
+				remote = &LircRemote{}
+			case "codes", "raw_codes":
+				if remote == nil {
+					return nil, fmt.Errorf("line %d: %v outside of a remote", lineNumber, fields[1])
+				}
+			}
+			section = strings.ToLower(fields[1])
+			continue
+		case keyword == "end" && len(fields) == 2:
+			if strings.ToLower(fields[1]) == "remote" && remote != nil {
+				remotes = append(remotes, *remote)
+				remote = nil
+			}
+			section, code = "remote", nil
+			continue
+		}
+
+		if remote == nil {
+			continue
+		}
+
+		switch section {
+		case "codes":
+			lircCode := LircCode{Name: fields[0]}
+			for _, field := range fields[1:] {
+				value, err := strconv.ParseUint(field, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid code %q", lineNumber, field)
+				}
+				lircCode.Data = append(lircCode.Data, value)
+			}
+			remote.Codes = append(remote.Codes, lircCode)
+		case "raw_codes":
+			if keyword == "name" && len(fields) == 2 {
+				remote.Codes = append(remote.Codes, LircCode{Name: fields[1]})
+				code = &remote.Codes[len(remote.Codes)-1]
+				continue
+			}
+
+			if code == nil {
+				return nil, fmt.Errorf("line %d: pulses without a name", lineNumber)
+			}
+
+			for _, field := range fields {
+				value, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid pulse %q", lineNumber, field)
+				}
+				code.Pulses = append(code.Pulses, value)
+			}
+		case "remote":
+			if err = remote.set(keyword, fields[1:]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if remote != nil {
+		return nil, fmt.Errorf("remote %v is not terminated", remote.Name)
+	}
+
+	return remotes, nil
+}
+
+// set stores a parameter of the remote, unknown parameters are ignored
+func (remote *LircRemote) set(keyword string, values []string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("%v without value", keyword)
+	}
+
+	if keyword == "name" {
+		remote.Name = values[0]
+		return nil
+	}
+
+	if keyword == "flags" {
+		remote.Flags = strings.Split(strings.ToUpper(values[0]), "|")
+		return nil
+	}
+
+	var numbers []uint64
+	for _, value := range values {
+		number, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q of %v", value, keyword)
+		}
+		numbers = append(numbers, number)
+	}
+
+	pair := func(target *[2]int) error {
+		if len(numbers) != 2 {
+			return fmt.Errorf("%v needs a pulse and a space", keyword)
+		}
+		target[0], target[1] = int(numbers[0]), int(numbers[1])
+		return nil
+	}
+
+	switch keyword {
+	case "bits":
+		remote.Bits = uint(numbers[0])
+	case "header":
+		return pair(&remote.Header)
+	case "one":
+		return pair(&remote.One)
+	case "zero":
+		return pair(&remote.Zero)
+	case "pre":
+		return pair(&remote.Pre)
+	case "post":
+		return pair(&remote.Post)
+	case "plead":
+		remote.Plead = int(numbers[0])
+	case "ptrail":
+		remote.Ptrail = int(numbers[0])
+	case "pre_data_bits":
+		remote.PreDataBits = uint(numbers[0])
+	case "pre_data":
+		remote.PreData = numbers[0]
+	case "post_data_bits":
+		remote.PostDataBits = uint(numbers[0])
+	case "post_data":
+		remote.PostData = numbers[0]
+	case "gap":
+		remote.Gap = int(numbers[0])
+	case "frequency":
+		remote.Frequency = uint(numbers[0])
+	case "rc6_mask":
+		remote.RC6Mask = numbers[0]
+	case "min_repeat":
+		remote.MinRepeat = uint(numbers[0])
+	}
+
+	return nil
+}
+
+// hasFlag reports if the remote has the flag set
+func (remote LircRemote) hasFlag(flag string) bool {
+	for _, f := range remote.Flags {
+		if f == flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Pulses expands a code of the remote into pulse and space durations in µs, ending with the gap after the code.
+// RC5 and RC6 codes are bi-phase coded like lircd does: a one is a space followed by a pulse, a zero a pulse followed by a space.
+// ErrLircEncoding is returned for other encodings than pulse distance, pulse width and bi-phase.
+func (remote LircRemote) Pulses(code LircCode) ([]int, error) {
+	var train pulseTrain
+
+	if len(code.Pulses) != 0 {
+		for i, duration := range code.Pulses {
+			train.add(i%2 == 0, duration)
+		}
+		train.add(false, remote.Gap)
+		return train, nil
+	}
+
+	for _, flag := range remote.Flags {
+		switch flag {
+		case "RCMM", "GRUNDIG", "BO", "SERIAL", "XMP":
+			return nil, fmt.Errorf("%w: remote %v uses %v", ErrLircEncoding, remote.Name, flag)
+		}
+	}
+
+	if len(code.Data) == 0 {
+		return nil, fmt.Errorf("remote %v: code %v has no data", remote.Name, code.Name)
+	}
+
+	biphase := remote.hasFlag("RC5") || remote.hasFlag("SHIFT_ENC") || remote.hasFlag("RC6")
+	allBits := remote.PreDataBits + remote.Bits + remote.PostDataBits
+	done := uint(0)
+
+	bits := func(value uint64, count uint) {
+		for i := uint(0); i < count; i++ {
+			bit := count - 1 - i
+			if remote.hasFlag("REVERSE") {
+				bit = i
+			}
+
+			one := value&(1<<bit) != 0
+			timing := remote.Zero
+			if one {
+				timing = remote.One
+			}
+
+			// the trailer bit of RC6 codes is twice as long
+			if remote.RC6Mask&(1<<(allBits-1-done)) != 0 {
+				timing[0], timing[1] = 2*timing[0], 2*timing[1]
+			}
+			done++
+
+			if remote.hasFlag("SPACE_FIRST") || biphase && one {
+				train.add(false, timing[1])
+				train.add(true, timing[0])
+			} else {
+				train.add(true, timing[0])
+				train.add(false, timing[1])
+			}
+		}
+	}
+
+	for _, data := range code.Data {
+		done = 0
+		start := 0
+		for _, duration := range train {
+			start += duration
+		}
+
+		train.add(true, remote.Header[0])
+		train.add(false, remote.Header[1])
+		train.add(true, remote.Plead)
+		bits(remote.PreData, remote.PreDataBits)
+		train.add(true, remote.Pre[0])
+		train.add(false, remote.Pre[1])
+		bits(data, remote.Bits)
+		train.add(true, remote.Post[0])
+		train.add(false, remote.Post[1])
+		bits(remote.PostData, remote.PostDataBits)
+		train.add(true, remote.Ptrail)
+
+		gap := remote.Gap
+		if remote.hasFlag("CONST_LENGTH") {
+			// the gap is the length of the whole code
+			for _, duration := range train {
+				gap -= duration
+			}
+			gap += start
+		}
+		train.add(false, gap)
+	}
+
+	return train, nil
+}
+
+// ImportLircConf reads a lircd.conf file into a code library.
+// Each remote of the file becomes a remote of the library with its codes in Broadlink format, min_repeat becomes the repeat count of the codes.
+// Remotes with an unsupported encoding are skipped with a warning in the log.
+func ImportLircConf(r io.Reader) (*CodeLibrary, error) {
+	remotes, err := ParseLircConf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := NewCodeLibrary()
+	for _, remote := range remotes {
+		frequency := remote.Frequency
+		if frequency == 0 {
+			frequency = lircDefaultFrequency
+		}
+
+		if remote.MinRepeat > 0xff {
+			return nil, fmt.Errorf("remote %v: invalid min_repeat %d", remote.Name, remote.MinRepeat)
+		}
+
+		for _, lircCode := range remote.Codes {
+			pulses, err := remote.Pulses(lircCode)
+			if errors.Is(err, ErrLircEncoding) {
+				log.Printf("%v, remote skipped", err)
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			packet, err := lirc2broadlink(pulses)
+			if err != nil {
+				return nil, fmt.Errorf("%v/%v: %v", remote.Name, lircCode.Name, err)
+			}
+			packet[1] = byte(remote.MinRepeat)
+
+			code := NewBroadlinkCode(packet)
+			code.Frequency = frequency
+			if err = lib.Set(remote.Name+"/"+lircCode.Name, code); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return lib, nil
+}
+
+// ExportLircConf writes the IR codes of a code library as raw_codes remotes of a lircd.conf file, RF codes are skipped.
+// The lowest repeat count of the codes of a remote becomes its min_repeat, codes repeated more often repeat their pulses.
+func ExportLircConf(w io.Writer, lib *CodeLibrary) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "# lircd.conf exported from a Broadlink code library\n")
+
+	var remoteName string
+	var remoteCodes []LircCode
+	var repeats []int
+	var frequency uint
+	var gap int
+
+	flush := func() {
+		if len(remoteCodes) == 0 {
+			return
+		}
+
+		if frequency == 0 {
+			frequency = lircDefaultFrequency
+		}
+
+		if gap == 0 {
+			gap = defaultGap
+		}
+
+		minRepeat := repeats[0]
+		for _, repeat := range repeats {
+			if repeat < minRepeat {
+				minRepeat = repeat
+			}
+		}
+
+		fmt.Fprintf(buf, "\nbegin remote\n\n  name  %v\n  flags RAW_CODES\n  eps   30\n  aeps  100\n  frequency %d\n  gap   %d\n", remoteName, frequency, gap)
+		if minRepeat > 0 {
+			fmt.Fprintf(buf, "  min_repeat %d\n", minRepeat)
+		}
+		fmt.Fprintf(buf, "\n  begin raw_codes\n")
+		for n, code := range remoteCodes {
+			// repeats above min_repeat are sent within the code, separated by the gap
+			pulses := code.Pulses
+			for i := minRepeat; i < repeats[n]; i++ {
+				pulses = append(append(pulses[:len(pulses):len(pulses)], gap), code.Pulses...)
+			}
+			code.Pulses = pulses
+
+			fmt.Fprintf(buf, "\n    name %v\n", code.Name)
+			for i := 0; i < len(code.Pulses); i += 6 {
+				end := i + 6
+				if end > len(code.Pulses) {
+					end = len(code.Pulses)
+				}
+
+				var line []string
+				for _, duration := range code.Pulses[i:end] {
+					line = append(line, fmt.Sprintf("%7d", duration))
+				}
+				fmt.Fprintf(buf, "    %v\n", strings.Join(line, " "))
+			}
+		}
+		fmt.Fprintf(buf, "\n  end raw_codes\n\nend remote\n")
+	}
+
+	for _, name := range lib.Names() {
+		remote, button, _ := SplitCodeName(name)
+		if remote != remoteName {
+			flush()
+			remoteName, remoteCodes, repeats, frequency, gap = remote, nil, nil, 0, 0
+		}
+
+		code, _ := lib.Get(name)
+		packet, err := code.Packet()
+		if err != nil {
+			return fmt.Errorf("code %v: %v", name, err)
+		}
+
+		parsed, err := ParsePacket(packet)
+		if err != nil {
+			return fmt.Errorf("code %v: %v", name, err)
+		}
+		if parsed.Kind != PacketIR {
+			if LogWarnings {
+				log.Printf("code %v: %v code skipped", name, parsed.Kind)
+			}
+			continue
+		}
+
+		pulses := parsed.Pulses
+		// the trailing space of the code becomes the gap of the remote
+		if len(pulses)%2 == 0 {
+			if pulses[len(pulses)-1] > gap {
+				gap = pulses[len(pulses)-1]
+			}
+			pulses = pulses[:len(pulses)-1]
+		}
+
+		if frequency == 0 {
+			frequency = code.Frequency
+		}
+
+		remoteCodes = append(remoteCodes, LircCode{Name: button, Pulses: pulses})
+		repeats = append(repeats, int(parsed.Repeat))
+	}
+	flush()
+
+	return buf.Flush()
+}
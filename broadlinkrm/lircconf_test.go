@@ -0,0 +1,189 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const lircConf = `
+# remotes in the formats of the lirc remote database
+begin remote
+  name  tv
+  bits           16
+  flags SPACE_ENC|CONST_LENGTH
+  eps            30
+  aeps          100
+  header       9000  4500
+  one           560  1690
+  zero          560   560
+  ptrail        560
+  pre_data_bits  16
+  pre_data       0x20DF
+  gap          108000
+  min_repeat      2
+  begin codes
+    power        0x10EF
+  end codes
+end remote
+
+begin remote
+  name  amp
+  bits           13
+  flags RC5|CONST_LENGTH
+  one           889   889
+  zero          889   889
+  plead         889
+  gap          113792
+  begin codes
+    mute         0x100D
+  end codes
+end remote
+
+begin remote
+  name  mce
+  bits           16
+  flags RC6|CONST_LENGTH
+  header       2667   889
+  one           444   444
+  zero          444   444
+  pre_data_bits  21
+  pre_data       0x37FF0
+  gap          105000
+  rc6_mask     0x100000000
+  begin codes
+    power        0x7BF3
+  end codes
+end remote
+
+begin remote
+  name  settop
+  bits           12
+  flags RCMM
+  begin codes
+    ok           0x123
+  end codes
+end remote
+
+begin remote
+  name  fan
+  flags RAW_CODES
+  gap   20000
+  begin raw_codes
+    name speed
+      1300 400 1300 400
+      400
+  end raw_codes
+end remote
+`
+
+func TestParseLircConf(t *testing.T) {
+	remotes, err := ParseLircConf(strings.NewReader(lircConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(remotes) != 5 {
+		t.Fatalf("got %d remotes, want 5", len(remotes))
+	}
+
+	tv := remotes[0]
+	if tv.Name != "tv" || tv.Bits != 16 || tv.Header != [2]int{9000, 4500} || tv.PreData != 0x20df || tv.MinRepeat != 2 || len(tv.Codes) != 1 || tv.Codes[0].Data[0] != 0x10ef {
+		t.Errorf("got remote %+v", tv)
+	}
+	if remotes[2].RC6Mask != 0x100000000 {
+		t.Errorf("got rc6_mask 0x%x", remotes[2].RC6Mask)
+	}
+	if fan := remotes[4]; len(fan.Codes) != 1 || len(fan.Codes[0].Pulses) != 5 {
+		t.Errorf("got raw codes %+v", fan.Codes)
+	}
+}
+
+func TestParseLircConfInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unterminated": "begin remote\n  name tv\n",
+		"invalid code": "begin remote\n  name tv\n  begin codes\n    power 0xZZ\n  end codes\nend remote\n",
+		"invalid pair": "begin remote\n  name tv\n  header 9000\nend remote\n",
+	}
+
+	for name, conf := range tests {
+		if _, err := ParseLircConf(strings.NewReader(conf)); err == nil {
+			t.Errorf("%v: no error", name)
+		}
+	}
+}
+
+func TestImportLircConf(t *testing.T) {
+	lib, err := ImportLircConf(strings.NewReader(lircConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		want   string
+		repeat byte
+	}{
+		{"tv/power", "NEC addr=0x04 cmd=0x08", 2},
+		{"amp/mute", "RC5 addr=0x00 cmd=0x0d", 0},
+		{"mce/power", "RC6-6-32 addr=0x800f cmd=0x40c toggle", 0},
+	}
+
+	for _, test := range tests {
+		code, err := lib.Get(test.name)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		packet, _ := code.Packet()
+		ir, err := DecodeIR(packet)
+		if err != nil || ir.String() != test.want || packet[1] != test.repeat {
+			t.Errorf("%v: got %v with repeat %d, want %v with repeat %d (%v)", test.name, ir, packet[1], test.want, test.repeat, err)
+		}
+	}
+
+	if _, err := lib.Get("settop/ok"); err == nil {
+		t.Error("remote with unsupported encoding was imported")
+	}
+}
+
+func TestExportLircConf(t *testing.T) {
+	lib := NewCodeLibrary()
+	nec, _ := EncodeIR(IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	nec[1] = 1
+	lib.Set("tv/power", NewBroadlinkCode(nec))
+	mute, _ := EncodeIR(IRCode{Protocol: "NEC", Address: 0x04, Command: 0x09})
+	mute[1] = 3
+	lib.Set("tv/mute", NewBroadlinkCode(mute))
+	rf, _ := Packet{Kind: PacketRF433, Pulses: []int{300, 900}}.Marshal()
+	lib.Set("tv/light", NewBroadlinkCode(rf))
+
+	var buf bytes.Buffer
+	if err := ExportLircConf(&buf, lib); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "min_repeat 1") || strings.Contains(buf.String(), "light") {
+		t.Errorf("unexpected export:\n%v", buf.String())
+	}
+
+	imported, err := ImportLircConf(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the two repeats above min_repeat are part of the pulses
+	code, err := imported.Get("tv/mute")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, _ := code.Packet()
+	parsed, _ := ParsePacket(packet)
+	if ir, err := DecodeIR(packet); err != nil || ir.Command != 0x09 || parsed.Repeat != 1 || len(parsed.Pulses) != 3*68 {
+		t.Errorf("got %v with repeat %d and %d durations (%v)", ir, parsed.Repeat, len(parsed.Pulses), err)
+	}
+}
@@ -0,0 +1,253 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxMacroDepth limits the nesting of macros running other macros
+const maxMacroDepth = 16
+
+// ErrMacroNotFound is returned if a macro name is not in the macro library
+var ErrMacroNotFound = errors.New("macro not found")
+
+// MacroStep is one step of a macro. Exactly one of Send, Delay or Run is set.
+type MacroStep struct {
+	// Send is the name "remote/button" of the code to send
+	Send string `json:"send,omitempty" yaml:"send,omitempty"`
+	// Device the code is send with, empty for the default device of the runner
+	Device string `json:"device,omitempty" yaml:"device,omitempty"`
+	// Delay to wait, e.g. "5s" or "500ms"
+	Delay string `json:"delay,omitempty" yaml:"delay,omitempty"`
+	// Run is the name of another macro to run
+	Run string `json:"run,omitempty" yaml:"run,omitempty"`
+	// Repeat the send or run step this many times, 0 and 1 execute it once
+	Repeat int `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+}
+
+// Macro is a timed sequence of codes send over one or more devices
+type Macro struct {
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []MacroStep `json:"steps" yaml:"steps"`
+}
+
+// MacroLibrary holds named macros
+type MacroLibrary struct {
+	Macros map[string]*Macro `json:"macros" yaml:"macros"`
+}
+
+// StepResult holds the outcome of one executed macro step
+type StepResult struct {
+	Macro    string
+	Step     int
+	Action   string
+	Code     string
+	Device   string
+	Duration time.Duration
+	Err      error
+}
+
+// MacroRunner executes macros from a macro library with codes from a code library
+type MacroRunner struct {
+	Macros *MacroLibrary
+	Codes  *CodeLibrary
+	// Device returns the authenticated device to use for the device name of a step
+	Device func(name string) (*Device, error)
+	// Send transmits packet with the device of a step, if nil the packet is send with Command(2, ...) to the device returned by Device
+	Send func(device string, packet []byte) error
+	// DryRun skips sending codes and waiting for delays
+	DryRun bool
+	// Toggler flips the toggle bit of RC5 and RC6 codes between sends if set
+	Toggler *Toggler
+}
+
+// NewMacroLibrary creates an empty macro library
+func NewMacroLibrary() *MacroLibrary {
+	return &MacroLibrary{Macros: make(map[string]*Macro)}
+}
+
+// LoadMacroLibrary reads a macro library from a JSON or YAML file, the format is selected by the file extension.
+func LoadMacroLibrary(path string) (*MacroLibrary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := NewMacroLibrary()
+	if isJSONFile(path) {
+		err = json.Unmarshal(data, lib)
+	} else {
+		err = yaml.Unmarshal(data, lib)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("macro library %v: %v", path, err)
+	}
+
+	if lib.Macros == nil {
+		lib.Macros = make(map[string]*Macro)
+	}
+
+	return lib, nil
+}
+
+// Save writes the macro library to a JSON or YAML file, the format is selected by the file extension.
+func (lib *MacroLibrary) Save(path string) error {
+	var data []byte
+	var err error
+	if isJSONFile(path) {
+		data, err = json.MarshalIndent(lib, "", "  ")
+	} else {
+		data, err = yaml.Marshal(lib)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Names returns the sorted names of all macros in the library
+func (lib *MacroLibrary) Names() (names []string) {
+	for name := range lib.Macros {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// Merge adds all macros of other to the library, existing macros are replaced
+func (lib *MacroLibrary) Merge(other *MacroLibrary) {
+	for name, macro := range other.Macros {
+		lib.Macros[name] = macro
+	}
+}
+
+// Run executes the macro with the given name.
+// Execution stops at the first failing step, the results of all executed steps are returned.
+func (runner *MacroRunner) Run(name string) ([]StepResult, error) {
+	return runner.run(name, nil)
+}
+
+func (runner *MacroRunner) run(name string, stack []string) (results []StepResult, err error) {
+	for _, running := range stack {
+		if running == name {
+			return nil, fmt.Errorf("macro %v runs itself: %v", name, strings.Join(append(stack, name), " -> "))
+		}
+	}
+
+	if len(stack) >= maxMacroDepth {
+		return nil, fmt.Errorf("macro %v: nesting deeper than %d macros", name, maxMacroDepth)
+	}
+
+	macro, found := runner.Macros.Macros[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %v", ErrMacroNotFound, name)
+	}
+
+	stack = append(stack, name)
+	for i, step := range macro.Steps {
+		repeat := step.Repeat
+		if repeat < 1 {
+			repeat = 1
+		}
+
+		switch {
+		case len(step.Send) != 0:
+			for n := 0; n < repeat; n++ {
+				result := runner.send(step)
+				result.Macro, result.Step = name, i+1
+				results = append(results, result)
+				if result.Err != nil {
+					return results, result.Err
+				}
+			}
+		case len(step.Delay) != 0:
+			result := StepResult{Macro: name, Step: i + 1, Action: "delay"}
+			result.Duration, result.Err = time.ParseDuration(step.Delay)
+			if result.Err == nil && !runner.DryRun {
+				time.Sleep(result.Duration)
+			}
+
+			results = append(results, result)
+			if result.Err != nil {
+				return results, fmt.Errorf("macro %v step %d: %v", name, i+1, result.Err)
+			}
+		case len(step.Run) != 0:
+			for n := 0; n < repeat; n++ {
+				results = append(results, StepResult{Macro: name, Step: i + 1, Action: "run", Code: step.Run})
+				subResults, err := runner.run(step.Run, stack)
+				results = append(results, subResults...)
+				if err != nil {
+					return results, err
+				}
+			}
+		default:
+			err = fmt.Errorf("macro %v step %d: no action", name, i+1)
+			results = append(results, StepResult{Macro: name, Step: i + 1, Err: err})
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func (runner *MacroRunner) send(step MacroStep) (result StepResult) {
+	result = StepResult{Action: "send", Code: step.Send, Device: step.Device}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	code, err := runner.Codes.Get(step.Send)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	packet, err := code.Packet()
+	if err != nil {
+		result.Err = fmt.Errorf("code %v: %v", step.Send, err)
+		return
+	}
+
+	if runner.DryRun {
+		return
+	}
+
+	if runner.Toggler != nil {
+		packet = runner.Toggler.Apply(packet)
+	}
+
+	if runner.Send != nil {
+		result.Err = runner.Send(step.Device, packet)
+		return
+	}
+
+	dev, err := runner.Device(step.Device)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	if Command(2, packet, dev) == nil {
+		result.Err = fmt.Errorf("sending %v: %w", step.Send, ErrCommandFailed)
+	}
+
+	return
+}
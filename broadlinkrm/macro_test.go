@@ -0,0 +1,112 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newMacroRunner returns a dry running runner for macros with codes for tv/power, avr/power and avr/hdmi1
+func newMacroRunner(macros map[string]*Macro) *MacroRunner {
+	codes := NewCodeLibrary()
+	for _, name := range []string{"tv/power", "avr/power", "avr/hdmi1"} {
+		codes.Set(name, NewBroadlinkCode([]byte{0x26, 0x00, 0x02, 0x00, 0x12, 0x12, 0x0d, 0x05}))
+	}
+
+	return &MacroRunner{Macros: &MacroLibrary{Macros: macros}, Codes: codes, DryRun: true}
+}
+
+func TestMacroRun(t *testing.T) {
+	runner := newMacroRunner(map[string]*Macro{
+		"movie": {Steps: []MacroStep{
+			{Send: "tv/power", Device: "living"},
+			{Delay: "1h"},
+			{Run: "avr", Repeat: 2},
+		}},
+		"avr": {Steps: []MacroStep{
+			{Send: "avr/power", Device: "amp"},
+			{Send: "avr/hdmi1", Device: "amp", Repeat: 2},
+		}},
+	})
+
+	results, err := runner.Run("movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []string
+	for _, result := range results {
+		steps = append(steps, result.Macro+":"+result.Action+":"+result.Code)
+	}
+
+	want := []string{
+		"movie:send:tv/power", "movie:delay:", "movie:run:avr",
+		"avr:send:avr/power", "avr:send:avr/hdmi1", "avr:send:avr/hdmi1",
+		"movie:run:avr",
+		"avr:send:avr/power", "avr:send:avr/hdmi1", "avr:send:avr/hdmi1",
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("got steps %v, want %v", steps, want)
+	}
+
+	// a dry run does not wait for the delay
+	if delay := results[1]; delay.Duration.Hours() != 1 || delay.Step != 2 {
+		t.Errorf("got delay step %+v", delay)
+	}
+}
+
+func TestMacroRunErrors(t *testing.T) {
+	tests := map[string]map[string]*Macro{
+		"loop":          {"loop": {Steps: []MacroStep{{Run: "inner"}}}, "inner": {Steps: []MacroStep{{Run: "loop"}}}},
+		"missing code":  {"missing code": {Steps: []MacroStep{{Send: "tv/mute"}}}},
+		"invalid delay": {"invalid delay": {Steps: []MacroStep{{Send: "tv/power"}, {Delay: "soon"}}}},
+		"no action":     {"no action": {Steps: []MacroStep{{Device: "tv"}}}},
+	}
+
+	for name, macros := range tests {
+		if _, err := newMacroRunner(macros).Run(name); err == nil {
+			t.Errorf("%v: no error", name)
+		}
+	}
+
+	// the steps executed before the failing one are returned with the error
+	runner := newMacroRunner(map[string]*Macro{"movie": {Steps: []MacroStep{{Delay: "1ms"}, {Send: "tv/power", Device: "broken"}, {Send: "tv/power"}}}})
+	runner.DryRun = false
+	runner.Device = func(name string) (*Device, error) { return nil, ErrCommandFailed }
+	if results, err := runner.Run("movie"); !errors.Is(err, ErrCommandFailed) || len(results) != 2 {
+		t.Errorf("got %d results (%v), want 2 results and ErrCommandFailed", len(results), err)
+	}
+
+	if _, err := newMacroRunner(nil).Run("unknown"); !errors.Is(err, ErrMacroNotFound) {
+		t.Errorf("got %v, want ErrMacroNotFound", err)
+	}
+}
+
+func TestMacroLibrary(t *testing.T) {
+	lib := NewMacroLibrary()
+	lib.Macros["movie"] = &Macro{Description: "movie night", Steps: []MacroStep{{Send: "tv/power", Device: "living"}, {Delay: "2s"}, {Run: "avr", Repeat: 2}}}
+	lib.Macros["avr"] = &Macro{Steps: []MacroStep{{Send: "avr/power"}}}
+
+	for _, file := range []string{"macros.yaml", "macros.json"} {
+		path := filepath.Join(t.TempDir(), file)
+		if err := lib.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadMacroLibrary(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, lib) {
+			t.Errorf("%v: got %+v, want %+v", file, loaded, lib)
+		}
+	}
+
+	if names := lib.Names(); !reflect.DeepEqual(names, []string{"avr", "movie"}) {
+		t.Errorf("got names %v", names)
+	}
+}
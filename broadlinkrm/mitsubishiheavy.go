@@ -0,0 +1,52 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Mitsubishi Heavy Industries remote timings in µs
+const (
+	mitsubishiHeavyHeaderPulse = 3140
+	mitsubishiHeavyHeaderSpace = 1630
+	mitsubishiHeavyBitPulse    = 370
+	mitsubishiHeavyOneSpace    = 420
+	mitsubishiHeavyZeroSpace   = 1220
+)
+
+// mitsubishiHeavyModel are the 152 bit codes of Mitsubishi Heavy ZJ-S remotes, each state byte is followed by its inverse
+var mitsubishiHeavyModel = acModel{
+	names:          []string{"mitsubishi-heavy", "mitsubishi-heavy-152"},
+	minTemperature: 17,
+	maxTemperature: 31,
+	modes:          map[string]uint32{"auto": 0, "cool": 1, "dry": 2, "fan": 3, "heat": 4},
+	fans:           map[string]uint32{"auto": 0, "low": 1, "medium": 2, "high": 3, "max": 4},
+	encode:         encodeMitsubishiHeavy,
+}
+
+func encodeMitsubishiHeavy(state ACState, mode uint32, fan uint32) []int {
+	data := []byte{0xad, 0x51, 0x3c, 0xe5, 0x1a, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	data[5] = byte(mode)
+	if state.Power {
+		data[5] |= 0x08
+	}
+	data[7] = byte(state.Temperature - 17)
+	data[9] = byte(fan)
+	// vertical swing auto or off, horizontal swing off
+	data[11] = 6 << 5
+	if state.Swing {
+		data[11] = 0
+	}
+	data[13] = 8
+
+	for i := 5; i < len(data); i += 2 {
+		data[i+1] = ^data[i]
+	}
+
+	var train pulseTrain
+	train.add(true, mitsubishiHeavyHeaderPulse)
+	train.add(false, mitsubishiHeavyHeaderSpace)
+	train.spaceBytes(data, mitsubishiHeavyBitPulse, mitsubishiHeavyOneSpace, mitsubishiHeavyZeroSpace)
+	train.add(true, mitsubishiHeavyBitPulse)
+	train.add(false, defaultGap)
+	return train
+}
@@ -0,0 +1,27 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeMitsubishiHeavy(t *testing.T) {
+	pulses := encodeMitsubishiHeavy(ACState{Power: true, Mode: "cool", Temperature: 24, Fan: "auto"}, 1, 0)
+	data := readSpaceBytes(t, pulses[2:], 19, mitsubishiHeavyBitPulse, mitsubishiHeavyOneSpace, mitsubishiHeavyZeroSpace)
+
+	want := []byte{0xad, 0x51, 0x3c, 0xe5, 0x1a, 0x09, 0xf6, 0x07, 0xf8, 0x00, 0xff, 0xc0, 0x3f, 0x08, 0xf7, 0x00, 0xff, 0x00, 0xff}
+	if !bytes.Equal(data, want) {
+		t.Errorf("got %x, want %x", data, want)
+	}
+
+	// each state byte is followed by its inverse
+	data = readSpaceBytes(t, encodeMitsubishiHeavy(ACState{Mode: "heat", Temperature: 31, Fan: "max", Swing: true}, 4, 4)[2:], 19, mitsubishiHeavyBitPulse, mitsubishiHeavyOneSpace, mitsubishiHeavyZeroSpace)
+	for i := 5; i < len(data); i += 2 {
+		if data[i+1] != ^data[i] {
+			t.Errorf("byte %d: got %02x following %02x", i+1, data[i+1], data[i])
+		}
+	}
+}
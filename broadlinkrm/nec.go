@@ -0,0 +1,122 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// NEC timings in µs, code and repeat frames start every necFramePeriod
+const (
+	necHeaderPulse = 9000
+	necHeaderSpace = 4500
+	necRepeatSpace = 2250
+	necBitPulse    = 560
+	necOneSpace    = 1690
+	necZeroSpace   = 560
+	necFramePeriod = 108000
+)
+
+// necProtocol sends repeats as repeat frames, an address above 0xff is send as NECx
+var necProtocol = IRProtocol{
+	Names:  []string{"NEC", "NECx"},
+	Decode: decodeNEC,
+	Encode: func(code IRCode) ([]int, int) {
+		return encodeNEC(code.Address, code.Command, code.Protocol == "NECx" || code.Address > 0xff, code.Repeats), 0
+	},
+	RepeatFrames: true,
+	Frequency:    38000,
+}
+
+// encodeNEC encodes a NEC code followed by repeat frames.
+// An extended code has a 16 bit address without its inverse (NECx), a command above 0xff is send as 16 bit without its inverse.
+func encodeNEC(address uint32, command uint32, extended bool, repeats int) []int {
+	var train pulseTrain
+	train.add(true, necHeaderPulse)
+	train.add(false, necHeaderSpace)
+
+	if extended {
+		train.spaceBits(uint64(address&0xffff), 16, necBitPulse, necOneSpace, necZeroSpace)
+	} else {
+		train.spaceBits(uint64(address&0xff|(^address&0xff)<<8), 16, necBitPulse, necOneSpace, necZeroSpace)
+	}
+
+	if command > 0xff {
+		train.spaceBits(uint64(command&0xffff), 16, necBitPulse, necOneSpace, necZeroSpace)
+	} else {
+		train.spaceBits(uint64(command|(^command&0xff)<<8), 16, necBitPulse, necOneSpace, necZeroSpace)
+	}
+
+	train.add(true, necBitPulse)
+	train.pad(necFramePeriod)
+
+	for i := 0; i < repeats; i++ {
+		start := train.length()
+		train.add(true, necHeaderPulse)
+		train.add(false, necRepeatSpace)
+		train.add(true, necBitPulse)
+		train.pad(start + necFramePeriod)
+	}
+
+	return train
+}
+
+// decodeNEC decodes a NEC or NECx code with the following repeat frames or a single repeat frame
+func decodeNEC(pulses []int) (code IRCode, ok bool) {
+	if len(pulses) < 3 || !matchDuration(pulses[0], necHeaderPulse) {
+		return code, false
+	}
+
+	code.Protocol = "NEC"
+	if isNECRepeat(pulses) {
+		code.RepeatFrame, code.Valid = true, true
+		return code, true
+	}
+
+	if len(pulses) < 67 || !matchDuration(pulses[1], necHeaderSpace) {
+		return code, false
+	}
+
+	var value uint32
+	for bit := 0; bit < 32; bit++ {
+		pulse, space := pulses[2+2*bit], pulses[3+2*bit]
+		switch {
+		case !matchDuration(pulse, necBitPulse):
+			return code, false
+		case matchDuration(space, necOneSpace):
+			value |= 1 << uint(bit)
+		case !matchDuration(space, necZeroSpace):
+			return code, false
+		}
+	}
+
+	if !matchDuration(pulses[66], necBitPulse) {
+		return code, false
+	}
+
+	address, inverseAddress := value&0xff, value>>8&0xff
+	command, inverseCommand := value>>16&0xff, value>>24
+
+	code.Address = address
+	if address != ^inverseAddress&0xff {
+		code.Protocol = "NECx"
+		code.Address = value & 0xffff
+	}
+
+	code.Command = command
+	code.Valid = command == ^inverseCommand&0xff
+	if !code.Valid {
+		code.Command = value >> 16
+	}
+
+	for next := pulses[67:]; len(next) > 1 && isNECRepeat(next[1:]); next = next[4:] {
+		code.Repeats++
+	}
+
+	return code, true
+}
+
+// isNECRepeat reports if pulses start with a NEC repeat frame
+func isNECRepeat(pulses []int) bool {
+	return len(pulses) >= 3 &&
+		matchDuration(pulses[0], necHeaderPulse) &&
+		matchDuration(pulses[1], necRepeatSpace) &&
+		matchDuration(pulses[2], necBitPulse)
+}
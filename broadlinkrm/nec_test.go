@@ -0,0 +1,80 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEncodeNEC(t *testing.T) {
+	packet, err := EncodeIR(IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// header 9000/4500 µs, then address 0x04 LSB first: 560/560 µs for 0 and 560/1690 µs for 1
+	if text := hex.EncodeToString(packet); !strings.HasPrefix(text, "26004800000128941212121212371212") || !strings.HasSuffix(text, "0d05") {
+		t.Errorf("got %v", text)
+	}
+
+	// a repeat frame every 108 ms follows the code
+	pulses := encodeNEC(0x04, 0x08, false, 2)
+	if len(pulses) != 68+2*4 || pulseTrain(pulses).length() != 3*necFramePeriod {
+		t.Errorf("got %d durations lasting %d µs", len(pulses), pulseTrain(pulses).length())
+	}
+}
+
+func TestDecodeNEC(t *testing.T) {
+	tests := []struct {
+		code IRCode
+		want string
+	}{
+		{IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08}, "NEC addr=0x04 cmd=0x08"},
+		{IRCode{Protocol: "NEC", Address: 0x00, Command: 0xff, Repeats: 3}, "NEC addr=0x00 cmd=0xff"},
+		{IRCode{Protocol: "NECx", Address: 0x04, Command: 0x08}, "NECx addr=0x04 cmd=0x08"},
+		// an address above 0xff is send as NECx
+		{IRCode{Protocol: "NEC", Address: 0xe0e0, Command: 0x40}, "NECx addr=0xe0e0 cmd=0x40"},
+		{IRCode{Protocol: "NEC", Address: 0x04, Command: 0x1234}, "NEC addr=0x04 cmd=0x1234 (invalid checksum)"},
+	}
+
+	for _, test := range tests {
+		packet, err := EncodeIR(test.code)
+		if err != nil {
+			t.Fatalf("%v: %v", test.code, err)
+		}
+
+		if code, err := DecodeIR(packet); err != nil || code.String() != test.want || code.Repeats != test.code.Repeats {
+			t.Errorf("%v: got %v with %d repeats (%v), want %v", test.code, code, code.Repeats, err, test.want)
+		}
+	}
+
+	if _, err := EncodeIR(IRCode{Protocol: "RCA"}); err == nil {
+		t.Error("no error for an unknown protocol")
+	}
+}
+
+func TestDecodeLearnedNEC(t *testing.T) {
+	// learned durations are off by some 10 µs
+	pulses := encodeNEC(0x04, 0x08, false, 2)
+	for i := range pulses {
+		pulses[i] += (i%3 - 1) * 40
+	}
+
+	code, ok := decodeNEC(pulses)
+	if !ok || code.String() != "NEC addr=0x04 cmd=0x08" || code.Repeats != 2 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+
+	// a learned repeat frame alone
+	if code, ok := decodeNEC([]int{9000, 2250, 560, 96000}); !ok || code.String() != "NEC repeat" {
+		t.Errorf("got %v", code)
+	}
+
+	// a frame cut off after 20 bits
+	if code, ok := decodeNEC(pulses[:42]); ok {
+		t.Errorf("got %v for a cut off frame", code)
+	}
+}
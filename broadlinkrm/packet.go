@@ -0,0 +1,154 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// PacketKind is the type byte a packet in Broadlink format starts with
+type PacketKind byte
+
+// Kinds of packets
+const (
+	PacketIR    PacketKind = 0x26
+	PacketRF433 PacketKind = 0xb2
+	PacketRF315 PacketKind = 0xd7
+)
+
+// broadlinkTick is the unit of the durations of a packet in µs, 32.84 ticks per ms
+const broadlinkTick = 8192.0 / 269
+
+// broadlinkMaxTicks is the longest duration of a single value, longer durations are split by values of zero length
+const broadlinkMaxTicks = 0xffff
+
+// broadlinkTrailer ends the pulses of a packet in Broadlink format
+var broadlinkTrailer = []byte{0x0d, 0x05}
+
+// Packet is an IR or RF code in Broadlink format split into its fields
+type Packet struct {
+	Kind PacketKind
+	// Repeat is the number of times the pulses are send again
+	Repeat uint8
+	// Pulses are the alternating pulse and space durations in µs, starting with a pulse
+	Pulses []int
+}
+
+// String returns the name of the kind, e.g. "RF433"
+func (kind PacketKind) String() string {
+	switch kind {
+	case PacketIR:
+		return "IR"
+	case PacketRF433:
+		return "RF433"
+	case PacketRF315:
+		return "RF315"
+	}
+
+	return fmt.Sprintf("0x%02x", byte(kind))
+}
+
+// ParsePacket splits a packet in Broadlink format into its fields.
+// The pulses end with the trailer 0x0d 0x05, which is either counted by the length or follows it.
+// Durations split by values of zero length are joined, so a packet written by Marshal is parsed into the durations it was written from, rounded to whole ticks.
+func ParsePacket(data []byte) (packet Packet, err error) {
+	if len(data) < 6 {
+		return packet, fmt.Errorf("broadlink code too short: %d bytes", len(data))
+	}
+
+	packet.Kind, packet.Repeat = PacketKind(data[0]), data[1]
+	switch packet.Kind {
+	case PacketIR, PacketRF433, PacketRF315:
+	default:
+		return packet, fmt.Errorf("broadlink code starts with unknown type 0x%02x", data[0])
+	}
+
+	pulseLen := int(binary.LittleEndian.Uint16(data[2:4]))
+	if pulseLen+4 > len(data) {
+		return packet, fmt.Errorf("broadlink code length %d exceeds the %d bytes of the code", pulseLen, len(data)-4)
+	}
+
+	pulses := data[4 : pulseLen+4]
+	switch {
+	case pulseLen+6 <= len(data) && bytes.Equal(data[pulseLen+4:pulseLen+6], broadlinkTrailer):
+	case bytes.HasSuffix(pulses, broadlinkTrailer):
+		pulses = pulses[:pulseLen-2]
+	default:
+		return packet, errors.New("broadlink code does not end with 0x0d 0x05")
+	}
+
+	var ticks []int
+	for i := 0; i < len(pulses); i++ {
+		value := int(pulses[i])
+		if value == 0 {
+			if i+2 >= len(pulses) {
+				return packet, errors.New("broadlink code ends within a pulse")
+			}
+
+			value = int(binary.BigEndian.Uint16(pulses[i+1 : i+3]))
+			i += 2
+		}
+		ticks = append(ticks, value)
+	}
+
+	for i := 0; i < len(ticks); i++ {
+		duration := ticks[i]
+		// a value of zero length joins the durations around it
+		for i+2 < len(ticks) && ticks[i+1] == 0 {
+			duration += ticks[i+2]
+			i += 2
+		}
+		packet.Pulses = append(packet.Pulses, int(math.Round(float64(duration)*broadlinkTick)))
+	}
+
+	return packet, nil
+}
+
+// Marshal returns the packet in Broadlink format ready to be send with Command(2, ...)
+// The durations are rounded to whole ticks of at least one tick, durations longer than 0xffff ticks (about 2 s)
+// are split by values of zero length. ParsePacket of the result followed by Marshal returns the same bytes.
+func (packet Packet) Marshal() ([]byte, error) {
+	var pulses []byte
+	value := func(ticks int) {
+		if ticks > 0 && ticks < 256 {
+			pulses = append(pulses, byte(ticks))
+		} else {
+			pulses = append(pulses, 0, byte(ticks>>8), byte(ticks))
+		}
+	}
+
+	for i, duration := range packet.Pulses {
+		if duration < 0 {
+			return nil, fmt.Errorf("negative duration %d µs at position %d", duration, i)
+		}
+
+		// a value of zero length joins the durations around it, so no duration is shorter than a tick
+		ticks := int(math.Round(float64(duration) / broadlinkTick))
+		if ticks == 0 {
+			ticks = 1
+		}
+		for ; ticks > broadlinkMaxTicks; ticks -= broadlinkMaxTicks {
+			value(broadlinkMaxTicks)
+			value(0)
+		}
+		value(ticks)
+	}
+
+	if len(pulses) > 0xffff {
+		return nil, fmt.Errorf("broadlink code too long: %d bytes of pulses", len(pulses))
+	}
+
+	data := []byte{byte(packet.Kind), packet.Repeat}
+	packetLen := make([]byte, 2)
+	binary.LittleEndian.PutUint16(packetLen, uint16(len(pulses)))
+	data = append(data, packetLen...)
+	data = append(data, pulses...)
+	data = append(data, broadlinkTrailer...)
+
+	return data, nil
+}
@@ -0,0 +1,181 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Packet
+	}{
+		{"trailer counted", "26010400" + "1212" + "0d05", Packet{Kind: PacketIR, Repeat: 1, Pulses: []int{548, 548}}},
+		{"trailer following", "26000200" + "1212" + "0d05", Packet{Kind: PacketIR, Pulses: []int{548, 548}}},
+		{"extended value", "b2000300" + "001c20" + "0d05", Packet{Kind: PacketRF433, Pulses: []int{219265}}},
+		{"rf 315", "d7020100" + "12" + "0d05", Packet{Kind: PacketRF315, Repeat: 2, Pulses: []int{548}}},
+		{"joined values", "d7000700" + "00ffff00000001" + "0d05", Packet{Kind: PacketRF315, Pulses: []int{1995803}}},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		packet, err := ParsePacket(data)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(packet, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.name, packet, test.want)
+		}
+	}
+}
+
+func TestParsePacketInvalid(t *testing.T) {
+	tests := map[string]string{
+		"too short":         "26000000",
+		"unknown type":      "27000200" + "1212" + "0d05",
+		"length too long":   "26000900" + "1212" + "0d05",
+		"no trailer":        "26000200" + "1212" + "0000",
+		"truncated pulse":   "26000400" + "1200" + "0d05",
+		"truncated trailer": "26000200" + "1212" + "0d",
+	}
+
+	for name, code := range tests {
+		data, _ := hex.DecodeString(code)
+		if _, err := ParsePacket(data); err == nil {
+			t.Errorf("%v: no error for %v", name, code)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		packet Packet
+		want   string
+	}{
+		{Packet{Kind: PacketIR, Pulses: []int{560, 560}}, "26000200" + "1212" + "0d05"},
+		// durations are rounded to whole ticks of 30.45 µs, 548 µs are 17.99 ticks
+		{Packet{Kind: PacketIR, Pulses: []int{548, 548}}, "26000200" + "1212" + "0d05"},
+		// 1.48 ticks are rounded down, 1.51 ticks up
+		{Packet{Kind: PacketIR, Pulses: []int{45, 46}}, "26000200" + "0102" + "0d05"},
+		// the kind and the repeat count are kept, the trailer follows the counted pulses
+		{Packet{Kind: PacketRF433, Repeat: 2, Pulses: []int{500}}, "b2020100" + "10" + "0d05"},
+		// durations of 256 ticks and more are written as 0x00 and two bytes
+		{Packet{Kind: PacketRF315, Pulses: []int{219300}}, "d7000300" + "001c21" + "0d05"},
+	}
+
+	for _, test := range tests {
+		if data, err := test.packet.Marshal(); err != nil || hex.EncodeToString(data) != test.want {
+			t.Errorf("%+v: got %x (%v), want %v", test.packet, data, err, test.want)
+		}
+	}
+}
+
+func TestMarshalLongDuration(t *testing.T) {
+	data, err := Packet{Kind: PacketIR, Pulses: []int{560, 3000000}}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 s are 98525 ticks, split into 0xffff ticks, a value of zero length and the remaining 0x80d0 ticks
+	want := "26000a00" + "12" + "00ffff" + "000000" + "0080d0" + "0d05"
+	if hex.EncodeToString(data) != want {
+		t.Fatalf("got %x, want %v", data, want)
+	}
+
+	packet, err := ParsePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packet.Pulses, []int{548, 3000008}) {
+		t.Errorf("got pulses %v", packet.Pulses)
+	}
+}
+
+func TestMarshalShortDuration(t *testing.T) {
+	data, err := Packet{Kind: PacketIR, Pulses: []int{560, 0, 560, 10}}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := ParsePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packet.Pulses, []int{548, 30, 548, 30}) {
+		t.Errorf("durations shorter than a tick were not kept: %v", packet.Pulses)
+	}
+}
+
+func TestMarshalNegativeDuration(t *testing.T) {
+	if _, err := (Packet{Kind: PacketIR, Pulses: []int{560, -1}}).Marshal(); err == nil {
+		t.Error("no error for a negative duration")
+	}
+}
+
+// quickPulses turns random values into durations from 0 to about 5 s
+func quickPulses(values []uint32) []int {
+	pulses := make([]int, len(values))
+	for i, value := range values {
+		// mostly durations of IR codes, some of them longer than 0xffff ticks
+		if value%8 == 0 {
+			pulses[i] = int(value % 5000000)
+		} else {
+			pulses[i] = int(value % 20000)
+		}
+	}
+
+	return pulses
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	// parsing a marshalled packet returns its durations within half a tick
+	durations := func(values []uint32, repeat uint8) bool {
+		pulses := quickPulses(values)
+		data, err := Packet{Kind: PacketIR, Repeat: repeat, Pulses: pulses}.Marshal()
+		if err != nil {
+			return false
+		}
+
+		packet, err := ParsePacket(data)
+		if err != nil || packet.Repeat != repeat || len(packet.Pulses) != len(pulses) {
+			return false
+		}
+
+		for i, pulse := range pulses {
+			if pulse > 15 && (packet.Pulses[i] < pulse-16 || packet.Pulses[i] > pulse+16) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(durations, nil); err != nil {
+		t.Error(err)
+	}
+
+	// packets written by Marshal are written again byte by byte
+	exact := func(values []uint32) bool {
+		data, err := Packet{Kind: PacketRF433, Pulses: quickPulses(values)}.Marshal()
+		if err != nil {
+			return false
+		}
+
+		packet, err := ParsePacket(data)
+		if err != nil {
+			return false
+		}
+
+		again, err := packet.Marshal()
+		return err == nil && bytes.Equal(again, data)
+	}
+	if err := quick.Check(exact, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Formats of pronto codes, given by their first word
+const (
+	prontoLearned     = 0x0000
+	prontoUnmodulated = 0x0100
+	prontoRC5         = 0x5000
+	prontoRC5X        = 0x5001
+	prontoRC6         = 0x6000
+	prontoNEC         = 0x900a
+)
+
+// ProntoIRCode returns the code of a pronto code in one of the predefined formats 5000 (RC5), 6000 (RC6) and 900A (NEC).
+// Learned pronto codes return ErrUnknownProtocol, the RC5X format 5001 returns an error as it is not supported.
+func ProntoIRCode(prontoByte []byte) (code IRCode, err error) {
+	if len(prontoByte) < 12 {
+		return code, fmt.Errorf("%w: pronto code too short", ErrUnknownProtocol)
+	}
+
+	words := make([]uint32, len(prontoByte)/2)
+	for i := range words {
+		words[i] = uint32(binary.BigEndian.Uint16(prontoByte[2*i:]))
+	}
+
+	code.Frequency = ProntoFrequency(prontoByte)
+	code.Valid = true
+	switch words[0] {
+	case prontoRC5:
+		code.Protocol, code.Address, code.Command = "RC5", words[4], words[5]
+	case prontoRC5X:
+		return code, errors.New("pronto format 5001 (RC5X) is not supported")
+	case prontoRC6:
+		code.Protocol, code.Address, code.Command = "RC6", words[4], words[5]
+	case prontoNEC:
+		code.Protocol = "NEC"
+		code.Address, code.Command = words[4]>>8, words[5]>>8
+		// a device byte without its inverse is the low byte of a NECx address
+		if words[4]&0xff != ^words[4]>>8&0xff {
+			code.Protocol, code.Address = "NECx", words[4]>>8|(words[4]&0xff)<<8
+		}
+		code.Valid = words[5]&0xff == ^words[5]>>8&0xff
+	default:
+		return code, fmt.Errorf("%w: pronto format %04x", ErrUnknownProtocol, words[0])
+	}
+
+	return code, nil
+}
+
+// EncodePronto encodes a RC5, RC6 or NEC code into a pronto code of the predefined format of its protocol.
+// The carrier frequency of the protocol is used if the code has none.
+func EncodePronto(code IRCode) ([]byte, error) {
+	var words []uint16
+	switch code.Protocol {
+	case "RC5":
+		words = []uint16{prontoRC5, 0, 0, 1, uint16(code.Address), uint16(code.Command)}
+	case "RC6":
+		words = []uint16{prontoRC6, 0, 0, 2, uint16(code.Address), uint16(code.Command)}
+	case "NEC":
+		words = []uint16{prontoNEC, 0, 0, 1, uint16(code.Address&0xff<<8 | ^code.Address&0xff), uint16(code.Command&0xff<<8 | ^code.Command&0xff)}
+	case "NECx":
+		words = []uint16{prontoNEC, 0, 0, 1, uint16(code.Address&0xff<<8 | code.Address>>8&0xff), uint16(code.Command&0xff<<8 | ^code.Command&0xff)}
+	default:
+		return nil, fmt.Errorf("no predefined pronto format for protocol %v", code.Protocol)
+	}
+
+	frequency := code.Frequency
+	if frequency == 0 {
+		protocol, _, _ := findProtocol(code.Protocol)
+		frequency = protocol.Frequency
+	}
+	words[1] = ProntoFrequencyWord(frequency)
+
+	prontoByte := make([]byte, 2*len(words))
+	for i, word := range words {
+		binary.BigEndian.PutUint16(prontoByte[2*i:], word)
+	}
+
+	return prontoByte, nil
+}
@@ -0,0 +1,110 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProntoIRCode(t *testing.T) {
+	tests := []struct {
+		pronto string
+		want   string
+	}{
+		{"5000 0073 0000 0001 0005 000c", "RC5 addr=0x05 cmd=0x0c"},
+		{"6000 0073 0000 0002 0000 000c", "RC6 addr=0x00 cmd=0x0c"},
+		{"900a 006d 0000 0001 04fb 08f7", "NEC addr=0x04 cmd=0x08"},
+		// the second device byte is the high byte of a NECx address
+		{"900a 006d 0000 0001 04e0 08f7", "NECx addr=0xe004 cmd=0x08"},
+		{"900a 006d 0000 0001 04fb 0808", "NEC addr=0x04 cmd=0x08 (invalid checksum)"},
+	}
+
+	for _, test := range tests {
+		code, err := ProntoIRCode(prontoBytes(t, test.pronto))
+		if err != nil || code.String() != test.want {
+			t.Errorf("%v: got %v (%v), want %v", test.pronto, code, err, test.want)
+		}
+	}
+
+	if _, err := ProntoIRCode(prontoBytes(t, "0000 006d 0001 0000 0157 00ab")); !errors.Is(err, ErrUnknownProtocol) {
+		t.Errorf("got %v for a learned code, want ErrUnknownProtocol", err)
+	}
+
+	// RC5X has an extra data field and is not supported
+	rc5x := prontoBytes(t, "5001 0073 0000 0002 0005 000c 0001 0000")
+	if _, err := ProntoIRCode(rc5x); err == nil || errors.Is(err, ErrUnknownProtocol) || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("got %v for format 5001", err)
+	}
+	if _, err := Pronto2Broadlink(rc5x); err == nil || !strings.Contains(err.Error(), "5001 (RC5X) is not supported") {
+		t.Errorf("got %v converting format 5001", err)
+	}
+}
+
+func TestEncodePronto(t *testing.T) {
+	tests := []struct {
+		code IRCode
+		want string
+	}{
+		{IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c}, "5000007300000001 0005000c"},
+		{IRCode{Protocol: "RC6", Address: 0x00, Command: 0x0c, Frequency: 36000}, "6000007300000002 0000000c"},
+		{IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08}, "900a006d00000001 04fb08f7"},
+		{IRCode{Protocol: "NECx", Address: 0xe004, Command: 0x08}, "900a006d00000001 04e008f7"},
+	}
+
+	for _, test := range tests {
+		pronto, err := EncodePronto(test.code)
+		if err != nil || !bytes.Equal(pronto, prontoBytes(t, test.want)) {
+			t.Errorf("%v: got %x (%v), want %v", test.code, pronto, err, test.want)
+			continue
+		}
+
+		// a predefined code converts to the packet of its protocol
+		want, _ := EncodeIR(test.code)
+		if packet := ConvertPronto2Broadlink(pronto); !bytes.Equal(packet, want) {
+			t.Errorf("%v: got packet %x, want %x", test.code, packet, want)
+		}
+	}
+
+	if _, err := EncodePronto(IRCode{Protocol: "SIRC", Address: 0x01, Command: 0x15}); err == nil {
+		t.Error("no error for a protocol without predefined format")
+	}
+}
+
+func TestProntoSequences(t *testing.T) {
+	tests := []struct {
+		pronto string
+		pulses []int
+		repeat uint8
+	}{
+		// only a once sequence
+		{"0000 006d 0002 0000 0157 00ab 0016 0f00", []int{9000, 4500, 579, 101000}, 0},
+		// only a repeat sequence
+		{"0000 006d 0000 0002 0157 00ab 0016 0f00", []int{9000, 4500, 579, 101000}, 0},
+		// a repeat sequence equal to the once sequence is send with the repeat count
+		{"0000 006d 0001 0001 0157 00ab 0157 00ab", []int{9000, 4500}, 1},
+		// different sequences are send one after the other, e.g. NEC with its repeat frame
+		{"0000 006d 0002 0002 0157 00ab 0016 0f00 0157 0055 0016 0f00", []int{9000, 4500, 579, 101000, 9000, 2237, 579, 101000}, 0},
+	}
+
+	for _, test := range tests {
+		packet := ConvertPronto2Broadlink(prontoBytes(t, test.pronto))
+		if !nearPulses(t, packet, test.pulses) || packet[1] != test.repeat {
+			t.Errorf("%v: got %x, want %v with repeat %d", test.pronto, packet, test.pulses, test.repeat)
+		}
+	}
+
+	// a repeated code becomes the once and the repeat sequence
+	packet, err := Packet{Kind: PacketIR, Repeat: 1, Pulses: []int{9000, 4500}}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pronto := ConvertBroadlink2Pronto(packet, 0x6d)
+	if len(pronto) != 16 || !bytes.Equal(pronto[:8], prontoBytes(t, "0000 006d 0001 0001")) || !bytes.Equal(pronto[8:12], pronto[12:]) {
+		t.Errorf("got %x", pronto)
+	}
+}
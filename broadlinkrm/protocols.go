@@ -0,0 +1,299 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultGap is the space in µs appended to codes that end without one
+const defaultGap = 100000
+
+// ErrUnknownProtocol is returned if pulses match no known IR protocol
+var ErrUnknownProtocol = errors.New("unknown IR protocol")
+
+// IRCode is an IR code described by its protocol instead of its pulses
+type IRCode struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Address  uint32 `json:"address" yaml:"address"`
+	Command  uint32 `json:"command" yaml:"command"`
+	// Extended device field of SIRC20 codes or vendor of Kaseikyo codes
+	Extended uint32 `json:"extended,omitempty" yaml:"extended,omitempty"`
+	// Valid is set if the checksum of the code is valid, e.g. the inverted command of NEC
+	Valid bool `json:"valid" yaml:"valid"`
+	// Toggle bit of RC5 and RC6 codes, it flips between two presses of a button
+	Toggle bool `json:"toggle,omitempty" yaml:"toggle,omitempty"`
+	// RepeatFrame is set if only a repeat frame without address and command was decoded
+	RepeatFrame bool `json:"repeat_frame,omitempty" yaml:"repeat_frame,omitempty"`
+	// Repeats is the number of repeat frames following the code
+	Repeats int `json:"repeats,omitempty" yaml:"repeats,omitempty"`
+	// Frequency in Hz of the IR carrier the protocol uses
+	Frequency uint `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+}
+
+// IRProtocol encodes and decodes the codes of an IR protocol
+type IRProtocol struct {
+	// Names of the protocol variants, the decoder sets one of them as protocol of the code
+	Names []string
+	// Decode decodes the code the pulses start with
+	Decode func(pulses []int) (IRCode, bool)
+	// Encode returns the pulses of a code and the repeat count of the packet sending them
+	Encode func(code IRCode) (pulses []int, repeat int)
+	// Validate returns an error if the fields of a code do not fit the protocol, it is optional
+	Validate func(code IRCode) error
+	// RepeatFrames is set if repeats are send as frames within the pulses instead of repeating the whole packet
+	RepeatFrames bool
+	// Frequency in Hz of the IR carrier
+	Frequency uint
+}
+
+// irProtocols are tried in order to decode pulses
+var irProtocols = []IRProtocol{
+	necProtocol,
+	samsungProtocol,
+	jvcProtocol,
+	kaseikyoProtocol,
+	sharpProtocol,
+	rc5Protocol,
+	rc6Protocol,
+	sircProtocol,
+}
+
+// RegisterProtocol adds a protocol, it is tried after the known protocols to decode pulses.
+// Protocols have to be registered before codes are decoded or encoded.
+func RegisterProtocol(protocol IRProtocol) {
+	irProtocols = append(irProtocols, protocol)
+}
+
+// findProtocol returns the protocol with the name, ignoring case, and the name as registered
+func findProtocol(name string) (IRProtocol, string, bool) {
+	for _, protocol := range irProtocols {
+		for _, variant := range protocol.Names {
+			if strings.EqualFold(variant, name) {
+				return protocol, variant, true
+			}
+		}
+	}
+
+	return IRProtocol{}, "", false
+}
+
+// String returns the code in the form "NEC addr=0x04 cmd=0x08"
+func (code IRCode) String() string {
+	if code.RepeatFrame {
+		return code.Protocol + " repeat"
+	}
+
+	text := fmt.Sprintf("%v addr=0x%02x cmd=0x%02x", code.Protocol, code.Address, code.Command)
+	if code.Extended != 0 {
+		text += fmt.Sprintf(" ext=0x%02x", code.Extended)
+	}
+	if code.Toggle {
+		text += " toggle"
+	}
+	if !code.Valid {
+		text += " (invalid checksum)"
+	}
+
+	return text
+}
+
+// IdentifyProtocol decodes pulse and space durations in µs with all registered protocols.
+// ErrUnknownProtocol is returned if the pulses match none of them.
+func IdentifyProtocol(pulses []int) (IRCode, error) {
+	code, _, err := identifyProtocol(pulses)
+	return code, err
+}
+
+// identifyProtocol returns the code and the protocol decoding it
+func identifyProtocol(pulses []int) (IRCode, IRProtocol, error) {
+	for _, protocol := range irProtocols {
+		if code, ok := protocol.Decode(pulses); ok {
+			code.Frequency = protocol.Frequency
+			return code, protocol, nil
+		}
+	}
+
+	return IRCode{}, IRProtocol{}, ErrUnknownProtocol
+}
+
+// DecodeIR decodes an IR packet in Broadlink format, e.g. a learned code, into its protocol, address and command.
+// ErrUnknownProtocol is returned if the pulses match no known protocol.
+func DecodeIR(packet []byte) (code IRCode, err error) {
+	pulses, err := irPulses(packet)
+	if err != nil {
+		return code, err
+	}
+
+	code, protocol, err := identifyProtocol(pulses)
+	if err != nil {
+		return code, err
+	}
+
+	// the repeat count of the packet sends the whole frame again
+	if !protocol.RepeatFrames {
+		code.Repeats += int(packet[1])
+	}
+
+	return code, nil
+}
+
+// InferFrequency returns the carrier frequency in Hz of the protocol of an IR packet in Broadlink format, 0 if the protocol is unknown
+func InferFrequency(packet []byte) uint {
+	code, err := DecodeIR(packet)
+	if err != nil {
+		return 0
+	}
+
+	return code.Frequency
+}
+
+// EncodeIR encodes a code with protocol, address and command into an IR packet in Broadlink format.
+// Protocols without repeat frames repeat the whole code with the repeat byte of the packet.
+func EncodeIR(code IRCode) ([]byte, error) {
+	protocol, name, found := findProtocol(code.Protocol)
+	if !found {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownProtocol, code.Protocol)
+	}
+
+	code.Protocol = name
+	if protocol.Validate != nil {
+		if err := protocol.Validate(code); err != nil {
+			return nil, err
+		}
+	}
+
+	pulses, repeat := protocol.Encode(code)
+	if repeat < 0 || repeat > 0xff {
+		return nil, fmt.Errorf("invalid repeat count %d", repeat)
+	}
+
+	packet, err := lirc2broadlink(pulses)
+	if err != nil {
+		return nil, err
+	}
+	packet[1] = byte(repeat)
+	return packet, nil
+}
+
+// halfBits splits bi-phase coded pulses into the levels of half bits with the given width in µs.
+// Durations may span up to maxUnits half bits, a longer space ends the code. The index of this space is returned as end.
+func halfBits(pulses []int, unit int, maxUnits int) (levels []bool, end int) {
+	for end = 0; end < len(pulses); end++ {
+		pulse := end%2 == 0
+		units := (pulses[end] + unit/2) / unit
+		switch {
+		case units == 0:
+			return nil, end
+		case units > maxUnits && !pulse:
+			return levels, end
+		case units > maxUnits:
+			return nil, end
+		}
+
+		for i := 0; i < units; i++ {
+			levels = append(levels, pulse)
+		}
+	}
+
+	return levels, end
+}
+
+// matchDuration reports if a measured duration in µs matches the expected one, learned codes are off by up to 25%
+func matchDuration(measured int, expected int) bool {
+	tolerance := expected/4 + 60
+	return measured >= expected-tolerance && measured <= expected+tolerance
+}
+
+// pulseTrain holds alternating pulse and space durations in µs, starting with a pulse
+type pulseTrain []int
+
+// add appends a pulse or space, durations of the same kind following each other are merged
+func (train *pulseTrain) add(pulse bool, duration int) {
+	if duration <= 0 || (len(*train) == 0 && !pulse) {
+		return
+	}
+
+	if (len(*train)%2 == 0) == pulse {
+		*train = append(*train, duration)
+	} else {
+		(*train)[len(*train)-1] += duration
+	}
+}
+
+// irPulses returns the pulse and space durations in µs of an IR packet in Broadlink format
+func irPulses(packet []byte) ([]int, error) {
+	parsed, err := ParsePacket(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Kind != PacketIR {
+		return nil, fmt.Errorf("%v code is no IR code", parsed.Kind)
+	}
+
+	return parsed.Pulses, nil
+}
+
+// length returns the sum of all durations in µs
+func (train pulseTrain) length() (length int) {
+	for _, duration := range train {
+		length += duration
+	}
+
+	return
+}
+
+// pad appends a space so the train lasts at least period µs
+func (train *pulseTrain) pad(period int) {
+	train.add(false, period-train.length())
+}
+
+// spaceBits appends count bits of value LSB first, each bit is a pulse followed by the space of a one or a zero
+func (train *pulseTrain) spaceBits(value uint64, count uint, pulse int, one int, zero int) {
+	for bit := uint(0); bit < count; bit++ {
+		train.add(true, pulse)
+		if value&(1<<bit) != 0 {
+			train.add(false, one)
+		} else {
+			train.add(false, zero)
+		}
+	}
+}
+
+// readSpaceBits reads count bits LSB first, each bit is a pulse followed by the space of a one or a zero
+func readSpaceBits(pulses []int, count int, pulse int, one int, zero int) (value uint64, ok bool) {
+	if len(pulses) < 2*count {
+		return 0, false
+	}
+
+	for bit := 0; bit < count; bit++ {
+		switch {
+		case !matchDuration(pulses[2*bit], pulse):
+			return 0, false
+		case matchDuration(pulses[2*bit+1], one):
+			value |= 1 << uint(bit)
+		case !matchDuration(pulses[2*bit+1], zero):
+			return 0, false
+		}
+	}
+
+	return value, true
+}
+
+// repeatedFrames returns the repeats of a code whose frame of length durations is followed by frames decoding to the same code
+func repeatedFrames(code IRCode, pulses []int, length int, decode func(pulses []int) (IRCode, bool)) int {
+	if len(pulses) <= length {
+		return 0
+	}
+
+	next, ok := decode(pulses[length:])
+	if !ok || next.Protocol != code.Protocol || next.Address != code.Address || next.Command != code.Command || next.Extended != code.Extended {
+		return 0
+	}
+
+	return next.Repeats + 1
+}
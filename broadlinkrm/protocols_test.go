@@ -0,0 +1,114 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIdentifyProtocol(t *testing.T) {
+	tests := []IRCode{
+		{Protocol: "NEC", Address: 0x04, Command: 0x08},
+		{Protocol: "Samsung32", Address: 0x07, Command: 0x02},
+		{Protocol: "JVC", Address: 0x03, Command: 0x17, Repeats: 1},
+		{Protocol: "Panasonic", Address: 0x08, Command: 0x3d},
+		{Protocol: "Sharp", Address: 0x01, Command: 0x16},
+		{Protocol: "Denon", Address: 0x02, Command: 0xe1},
+		{Protocol: "RC5", Address: 0x05, Command: 0x0c},
+		{Protocol: "RC6", Address: 0x00, Command: 0x0c},
+		{Protocol: "SIRC", Address: 0x01, Command: 0x15, Repeats: 2},
+	}
+
+	// every protocol is told apart from all others, also with learned durations
+	for _, test := range tests {
+		packet, err := EncodeIR(test)
+		if err != nil {
+			t.Fatalf("%v: %v", test, err)
+		}
+
+		pulses, _ := irPulses(packet)
+		for i := range pulses {
+			pulses[i] += (i%3 - 1) * 40
+		}
+
+		code, err := IdentifyProtocol(pulses)
+		if err != nil || code.Protocol != test.Protocol || code.Address != test.Address || code.Command != test.Command || !code.Valid {
+			t.Errorf("%v: got %v (%v)", test, code, err)
+		}
+
+		if code, err := DecodeIR(packet); err != nil || code.Repeats != test.Repeats {
+			t.Errorf("%v: got %v with %d repeats (%v)", test, code, code.Repeats, err)
+		}
+	}
+
+	if _, err := IdentifyProtocol([]int{1000, 1000, 1000, 20000}); !errors.Is(err, ErrUnknownProtocol) {
+		t.Errorf("got %v, want ErrUnknownProtocol", err)
+	}
+}
+
+func TestEncodeIR(t *testing.T) {
+	// protocol names are matched ignoring case, the repeat count of protocols without repeat frames is the packet's
+	packet, err := EncodeIR(IRCode{Protocol: "samsung32", Address: 0x07, Command: 0x02, Repeats: 3})
+	if err != nil || packet[0] != 0x26 || packet[1] != 3 {
+		t.Errorf("got %x (%v)", packet, err)
+	}
+
+	if _, err := EncodeIR(IRCode{Protocol: "RCA"}); !errors.Is(err, ErrUnknownProtocol) {
+		t.Errorf("got %v, want ErrUnknownProtocol", err)
+	}
+	if _, err := EncodeIR(IRCode{Protocol: "RC5", Repeats: 0x100}); err == nil {
+		t.Error("no error for a repeat count above 255")
+	}
+}
+
+func TestRegisterProtocol(t *testing.T) {
+	saved := irProtocols
+	defer func() { irProtocols = saved }()
+
+	// a protocol with a single 5 ms pulse, the command is the length of the space in ms
+	RegisterProtocol(IRProtocol{
+		Names: []string{"Test"},
+		Decode: func(pulses []int) (IRCode, bool) {
+			if len(pulses) < 2 || !matchDuration(pulses[0], 5000) {
+				return IRCode{}, false
+			}
+			return IRCode{Protocol: "Test", Command: uint32((pulses[1] + 500) / 1000), Valid: true}, true
+		},
+		Encode: func(code IRCode) ([]int, int) {
+			return []int{5000, int(code.Command) * 1000}, code.Repeats
+		},
+	})
+
+	packet, err := EncodeIR(IRCode{Protocol: "test", Command: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := DecodeIR(packet); err != nil || code.String() != "Test addr=0x00 cmd=0x1e" {
+		t.Errorf("got %v (%v)", code, err)
+	}
+}
+
+func TestInferFrequency(t *testing.T) {
+	tests := map[string]uint{"NEC": 38000, "RC5": 36000, "RC6": 36000, "SIRC": 40000, "Panasonic": 37000}
+
+	for protocol, want := range tests {
+		packet, _ := EncodeIR(IRCode{Protocol: protocol, Address: 0x01, Command: 0x02})
+		if got := InferFrequency(packet); got != want {
+			t.Errorf("%v: got %d Hz, want %d Hz", protocol, got, want)
+		}
+
+		if code, _ := DecodeIR(packet); code.Frequency != want {
+			t.Errorf("%v: got code %v at %d Hz", protocol, code, code.Frequency)
+		}
+	}
+
+	packet, err := lirc2broadlink([]int{1000, 1000, 1000, 20000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := InferFrequency(packet); got != 0 {
+		t.Errorf("got %d Hz for an unknown protocol", got)
+	}
+}
@@ -0,0 +1,72 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// RC5 timings in µs
+const (
+	rc5Unit        = 889
+	rc5FramePeriod = 113778
+)
+
+var rc5Protocol = IRProtocol{
+	Names:  []string{"RC5"},
+	Decode: decodeRC5,
+	Encode: func(code IRCode) ([]int, int) {
+		return encodeRC5(code.Address, code.Command, code.Toggle), code.Repeats
+	},
+	Frequency: 36000,
+}
+
+// encodeRC5 encodes a RC5 code with bi-phase coding, commands above 63 use the extended RC5X field bit
+func encodeRC5(address uint32, command uint32, toggle bool) []int {
+	// start bit, field bit (inverted bit 6 of the command), toggle, 5 bit address and 6 bit command
+	bits := uint32(1)<<13 | (^command>>6&1)<<12 | (address&0x1f)<<6 | command&0x3f
+	if toggle {
+		bits |= 1 << 11
+	}
+
+	var train pulseTrain
+	for bit := 13; bit >= 0; bit-- {
+		one := bits&(1<<uint(bit)) != 0
+		train.add(!one, rc5Unit)
+		train.add(one, rc5Unit)
+	}
+
+	train.pad(rc5FramePeriod)
+	return train
+}
+
+// decodeRC5 decodes a RC5 or RC5X code, a one is a space followed by a pulse
+func decodeRC5(pulses []int) (code IRCode, ok bool) {
+	levels, end := halfBits(pulses, rc5Unit, 2)
+	// the space of the first half of the start bit is not visible
+	levels = append([]bool{false}, levels...)
+	if len(levels)%2 != 0 {
+		levels = append(levels, false)
+	}
+
+	if len(levels) != 28 {
+		return code, false
+	}
+
+	var bits uint32
+	for i := 0; i < 14; i++ {
+		switch {
+		case !levels[2*i] && levels[2*i+1]:
+			bits = bits<<1 | 1
+		case levels[2*i] && !levels[2*i+1]:
+			bits = bits << 1
+		default:
+			return code, false
+		}
+	}
+
+	code.Protocol = "RC5"
+	code.Address = bits >> 6 & 0x1f
+	code.Command = bits&0x3f | (^bits>>12&1)<<6
+	code.Toggle = bits&(1<<11) != 0
+	code.Valid = true
+	code.Repeats = repeatedFrames(code, pulses, end+1, decodeRC5)
+	return code, true
+}
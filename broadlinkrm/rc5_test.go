@@ -0,0 +1,71 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRC5(t *testing.T) {
+	// start and field bit 1, toggle 0 and address bit 4 0: the pulses of the field and the toggle bit join
+	pulses := encodeRC5(0x05, 0x0c, false)
+	if !reflect.DeepEqual(pulses[:6], []int{889, 889, 1778, 889, 889, 889}) {
+		t.Errorf("got %v", pulses[:6])
+	}
+
+	if length := pulseTrain(pulses).length(); length != rc5FramePeriod {
+		t.Errorf("got frame length %d µs, want %d µs", length, rc5FramePeriod)
+	}
+}
+
+func TestDecodeRC5(t *testing.T) {
+	tests := []struct {
+		code IRCode
+		want string
+	}{
+		{IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c}, "RC5 addr=0x05 cmd=0x0c"},
+		{IRCode{Protocol: "RC5", Address: 0x00, Command: 0x3f, Toggle: true}, "RC5 addr=0x00 cmd=0x3f toggle"},
+		// commands above 63 clear the field bit (RC5X)
+		{IRCode{Protocol: "rc5", Address: 0x1f, Command: 0x41}, "RC5 addr=0x1f cmd=0x41"},
+	}
+
+	for _, test := range tests {
+		packet, err := EncodeIR(test.code)
+		if err != nil {
+			t.Fatalf("%v: %v", test.code, err)
+		}
+
+		if code, err := DecodeIR(packet); err != nil || code.String() != test.want {
+			t.Errorf("%v: got %v (%v), want %v", test.code, code, err, test.want)
+		}
+	}
+
+	// learned durations are off by some 10 µs
+	pulses := encodeRC5(0x05, 0x0c, true)
+	for i := range pulses {
+		pulses[i] += (i%3 - 1) * 40
+	}
+	if code, ok := decodeRC5(pulses); !ok || code.String() != "RC5 addr=0x05 cmd=0x0c toggle" {
+		t.Errorf("got %v", code)
+	}
+
+	// a half bit of three units is no bi-phase code
+	if code, ok := decodeRC5([]int{889, 2667, 889, 889}); ok {
+		t.Errorf("got %v", code)
+	}
+}
+
+func TestHalfBits(t *testing.T) {
+	// bi-phase durations of one and two units, the last space is the gap
+	levels, end := halfBits([]int{889, 1778, 889, 100000}, rc5Unit, 2)
+	if !reflect.DeepEqual(levels, []bool{true, false, false, true}) || end != 3 {
+		t.Errorf("got %v ending at %d", levels, end)
+	}
+
+	// a pulse longer than maxUnits is no bi-phase code
+	if levels, _ := halfBits([]int{889, 889, 5000, 100000}, rc5Unit, 2); levels != nil {
+		t.Errorf("got %v for a long pulse", levels)
+	}
+}
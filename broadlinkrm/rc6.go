@@ -0,0 +1,134 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// RC6 timings in µs
+const (
+	rc6Unit        = 444
+	rc6LeaderPulse = 6 * rc6Unit
+	rc6LeaderSpace = 2 * rc6Unit
+	rc6FramePeriod = 107000
+)
+
+var rc6Protocol = IRProtocol{
+	Names:  []string{"RC6", "RC6-6-32"},
+	Decode: decodeRC6,
+	Encode: func(code IRCode) ([]int, int) {
+		if code.Protocol == "RC6-6-32" {
+			return encodeRC6(6, code.Address, code.Command, code.Toggle), code.Repeats
+		}
+		return encodeRC6(0, code.Address, code.Command, code.Toggle), code.Repeats
+	},
+	Frequency: 36000,
+}
+
+// encodeRC6 encodes a RC6 code with bi-phase coding, a one is a pulse followed by a space.
+//
+// mode 0 sends 8 bit address and 8 bit command with the toggle in the trailer bit,
+// mode 6 with 32 bits (RC6-6-32, e.g. Windows Media Center) sends 16 bit address and 15 bit command with the toggle in bit 15.
+func encodeRC6(mode uint32, address uint32, command uint32, toggle bool) []int {
+	var train pulseTrain
+	train.add(true, rc6LeaderPulse)
+	train.add(false, rc6LeaderSpace)
+
+	bit := func(one bool, width int) {
+		train.add(one, width*rc6Unit)
+		train.add(!one, width*rc6Unit)
+	}
+
+	bit(true, 1)
+	for i := 2; i >= 0; i-- {
+		bit(mode&(1<<uint(i)) != 0, 1)
+	}
+
+	var data uint32
+	bits := 16
+	if mode == 6 {
+		bits = 32
+		data = address<<16 | command&0x7fff
+		if toggle {
+			data |= 1 << 15
+		}
+		bit(false, 2)
+	} else {
+		data = (address&0xff)<<8 | command&0xff
+		bit(toggle, 2)
+	}
+
+	for i := bits - 1; i >= 0; i-- {
+		bit(data&(1<<uint(i)) != 0, 1)
+	}
+
+	train.pad(rc6FramePeriod)
+	return train
+}
+
+// decodeRC6 decodes a RC6 mode 0 or a RC6-6-32 code
+func decodeRC6(pulses []int) (code IRCode, ok bool) {
+	if len(pulses) < 3 || !matchDuration(pulses[0], rc6LeaderPulse) || !matchDuration(pulses[1], rc6LeaderSpace) {
+		return code, false
+	}
+
+	levels, end := halfBits(pulses[2:], rc6Unit, 4)
+	if len(levels)%2 != 0 {
+		levels = append(levels, false)
+	}
+
+	if len(levels) < 12 {
+		return code, false
+	}
+
+	readBit := func(index int) (one bool, ok bool) {
+		return levels[index], levels[index] != levels[index+1]
+	}
+
+	var mode uint32
+	for i := 0; i < 4; i++ {
+		one, ok := readBit(2 * i)
+		if !ok || (i == 0 && !one) {
+			return code, false
+		}
+
+		if i > 0 && one {
+			mode |= 1 << uint(3-i)
+		}
+	}
+
+	// the trailer bit has double width
+	if levels[8] != levels[9] || levels[10] != levels[11] || levels[8] == levels[10] {
+		return code, false
+	}
+	trailer := levels[8]
+
+	var data uint32
+	bits := (len(levels) - 12) / 2
+	for i := 0; i < bits; i++ {
+		one, ok := readBit(12 + 2*i)
+		if !ok {
+			return code, false
+		}
+
+		data <<= 1
+		if one {
+			data |= 1
+		}
+	}
+
+	switch {
+	case mode == 0 && bits == 16:
+		code.Protocol = "RC6"
+		code.Address, code.Command = data>>8, data&0xff
+		code.Toggle = trailer
+	case mode == 6 && bits == 32:
+		code.Protocol = "RC6-6-32"
+		code.Address, code.Command = data>>16, data&0x7fff
+		code.Toggle = data&(1<<15) != 0
+	default:
+		return code, false
+	}
+
+	code.Valid = true
+	code.Repeats = repeatedFrames(code, pulses, end+3, decodeRC6)
+	return code, true
+}
@@ -0,0 +1,50 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRC6(t *testing.T) {
+	// leader, start bit 1 and mode bit 0: the spaces of the start and the mode bit join
+	if pulses := encodeRC6(0, 0x00, 0x0c, false); !reflect.DeepEqual(pulses[:4], []int{2664, 888, 444, 888}) {
+		t.Errorf("got %v", pulses[:4])
+	}
+
+	// the trailer bit holding the toggle lasts two units
+	if pulses := encodeRC6(0, 0x00, 0x0c, true); !reflect.DeepEqual(pulses[4:8], []int{444, 444, 444, 444}) {
+		t.Errorf("got %v", pulses[4:8])
+	}
+}
+
+func TestDecodeRC6(t *testing.T) {
+	tests := []struct {
+		code IRCode
+		want string
+	}{
+		{IRCode{Protocol: "RC6", Address: 0x00, Command: 0x0c}, "RC6 addr=0x00 cmd=0x0c"},
+		{IRCode{Protocol: "RC6", Address: 0x04, Command: 0xff, Toggle: true}, "RC6 addr=0x04 cmd=0xff toggle"},
+		// Windows Media Center remotes
+		{IRCode{Protocol: "RC6-6-32", Address: 0x800f, Command: 0x040c}, "RC6-6-32 addr=0x800f cmd=0x40c"},
+		{IRCode{Protocol: "RC6-6-32", Address: 0x800f, Command: 0x040c, Toggle: true}, "RC6-6-32 addr=0x800f cmd=0x40c toggle"},
+	}
+
+	for _, test := range tests {
+		packet, err := EncodeIR(test.code)
+		if err != nil {
+			t.Fatalf("%v: %v", test.code, err)
+		}
+
+		if code, err := DecodeIR(packet); err != nil || code.String() != test.want {
+			t.Errorf("%v: got %v (%v), want %v", test.code, code, err, test.want)
+		}
+	}
+
+	// a frame without its last bits
+	if code, ok := decodeRC6(encodeRC6(0, 0x04, 0x12, false)[:20]); ok {
+		t.Errorf("got %v for a cut off frame", code)
+	}
+}
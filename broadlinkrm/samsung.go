@@ -0,0 +1,54 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Samsung32 timings in µs, the bits are timed like NEC
+const (
+	samsungHeaderPulse = 4500
+	samsungHeaderSpace = 4500
+	samsungFramePeriod = 108000
+	// samsungFrameLength is the number of durations of a frame including the gap
+	samsungFrameLength = 68
+)
+
+// samsungProtocol repeats the whole frame
+var samsungProtocol = IRProtocol{
+	Names:  []string{"Samsung32"},
+	Decode: decodeSamsung,
+	Encode: func(code IRCode) ([]int, int) {
+		return encodeSamsung(code.Address, code.Command), code.Repeats
+	},
+	Frequency: 38000,
+}
+
+// encodeSamsung encodes a Samsung32 code, the 8 bit address is send twice followed by the command and its inverse
+func encodeSamsung(address uint32, command uint32) []int {
+	var train pulseTrain
+	train.add(true, samsungHeaderPulse)
+	train.add(false, samsungHeaderSpace)
+	train.spaceBits(uint64(address&0xff|(address&0xff)<<8), 16, necBitPulse, necOneSpace, necZeroSpace)
+	train.spaceBits(uint64(command&0xff|(^command&0xff)<<8), 16, necBitPulse, necOneSpace, necZeroSpace)
+	train.add(true, necBitPulse)
+	train.pad(samsungFramePeriod)
+	return train
+}
+
+// decodeSamsung decodes a Samsung32 code, identical frames following the first are counted as repeats
+func decodeSamsung(pulses []int) (code IRCode, ok bool) {
+	if len(pulses) < samsungFrameLength-1 || !matchDuration(pulses[0], samsungHeaderPulse) || !matchDuration(pulses[1], samsungHeaderSpace) {
+		return code, false
+	}
+
+	value, ok := readSpaceBits(pulses[2:], 32, necBitPulse, necOneSpace, necZeroSpace)
+	if !ok || !matchDuration(pulses[66], necBitPulse) {
+		return code, false
+	}
+
+	code.Protocol = "Samsung32"
+	code.Address = uint32(value & 0xff)
+	code.Command = uint32(value >> 16 & 0xff)
+	code.Valid = value>>8&0xff == value&0xff && value>>24 == ^value>>16&0xff
+	code.Repeats = repeatedFrames(code, pulses, samsungFrameLength, decodeSamsung)
+	return code, true
+}
@@ -0,0 +1,40 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+func TestEncodeSamsung(t *testing.T) {
+	// the address is send twice, the command with its inverse
+	pulses := encodeSamsung(0x07, 0x02)
+	if len(pulses) != samsungFrameLength || pulseTrain(pulses).length() != samsungFramePeriod {
+		t.Errorf("got %d durations lasting %d µs", len(pulses), pulseTrain(pulses).length())
+	}
+
+	if value, ok := readSpaceBits(pulses[2:], 32, necBitPulse, necOneSpace, necZeroSpace); !ok || value != 0xfd020707 {
+		t.Errorf("got frame 0x%08x", value)
+	}
+}
+
+func TestDecodeSamsung(t *testing.T) {
+	// learned durations are off by some 10 µs, the frame is repeated once
+	frame := encodeSamsung(0x07, 0x02)
+	pulses := append(append([]int(nil), frame...), frame...)
+	for i := range pulses {
+		pulses[i] += (i%3 - 1) * 40
+	}
+
+	if code, ok := decodeSamsung(pulses); !ok || code.String() != "Samsung32 addr=0x07 cmd=0x02" || code.Repeats != 1 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+
+	// bit 3 of the second address byte differs
+	pulses = append([]int(nil), frame...)
+	pulses[2+2*11+1] = necOneSpace
+	if code, ok := decodeSamsung(pulses); !ok || code.Valid {
+		t.Errorf("got %v, want an invalid checksum", code)
+	}
+}
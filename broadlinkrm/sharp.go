@@ -0,0 +1,76 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+// Sharp and Denon timings in µs, each frame is followed by a frame with inverted command
+const (
+	sharpBitPulse  = 260
+	sharpOneSpace  = 1820
+	sharpZeroSpace = 780
+	sharpGap       = 40000
+	// sharpFrameLength is the number of durations of a frame and its inverse including the gaps
+	sharpFrameLength = 64
+)
+
+// sharpExtension are the two bits following the command in the first frame
+var sharpExtension = map[string]uint32{"Sharp": 1, "Denon": 0}
+
+// sharpProtocol repeats the frame and its inverse
+var sharpProtocol = IRProtocol{
+	Names:  []string{"Sharp", "Denon"},
+	Decode: decodeSharp,
+	Encode: func(code IRCode) ([]int, int) {
+		return encodeSharp(code.Address, code.Command, sharpExtension[code.Protocol]), code.Repeats
+	},
+	Frequency: 38000,
+}
+
+// encodeSharp encodes a code with 5 bit address, 8 bit command and 2 extension bits, followed by the frame with command and extension inverted
+func encodeSharp(address uint32, command uint32, extension uint32) []int {
+	var train pulseTrain
+	for _, inverse := range []uint32{0, 0x3ff} {
+		value := address&0x1f | ((command&0xff|(extension&3)<<8)^inverse)<<5
+		train.spaceBits(uint64(value), 15, sharpBitPulse, sharpOneSpace, sharpZeroSpace)
+		train.add(true, sharpBitPulse)
+		train.add(false, sharpGap)
+	}
+
+	return train
+}
+
+// decodeSharp decodes a Sharp or Denon code, the code is valid if the inverted frame follows
+func decodeSharp(pulses []int) (code IRCode, ok bool) {
+	value, ok := readSharpFrame(pulses)
+	if !ok {
+		return code, false
+	}
+
+	switch value >> 13 {
+	case sharpExtension["Sharp"]:
+		code.Protocol = "Sharp"
+	case sharpExtension["Denon"]:
+		code.Protocol = "Denon"
+	default:
+		// an inverted frame
+		return code, false
+	}
+
+	code.Address, code.Command = value&0x1f, value>>5&0xff
+	if inverse, ok := readSharpFrame(pulses[sharpFrameLength/2:]); ok && inverse == value^0x7fe0 {
+		code.Valid = true
+		code.Repeats = repeatedFrames(code, pulses, sharpFrameLength, decodeSharp)
+	}
+
+	return code, true
+}
+
+// readSharpFrame reads the 15 bits of a frame followed by the stop bit
+func readSharpFrame(pulses []int) (uint32, bool) {
+	value, ok := readSpaceBits(pulses, 15, sharpBitPulse, sharpOneSpace, sharpZeroSpace)
+	if !ok || len(pulses) < 31 || !matchDuration(pulses[30], sharpBitPulse) {
+		return 0, false
+	}
+
+	return uint32(value), true
+}
@@ -0,0 +1,45 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"testing"
+)
+
+func TestEncodeSharp(t *testing.T) {
+	pulses := encodeSharp(0x01, 0x16, sharpExtension["Sharp"])
+	if len(pulses) != sharpFrameLength {
+		t.Fatalf("got %d durations, want %d", len(pulses), sharpFrameLength)
+	}
+
+	// the second frame keeps the address and inverts command and extension
+	first, _ := readSpaceBits(pulses, 15, sharpBitPulse, sharpOneSpace, sharpZeroSpace)
+	second, _ := readSpaceBits(pulses[sharpFrameLength/2:], 15, sharpBitPulse, sharpOneSpace, sharpZeroSpace)
+	if first != 0x22c1 || second != 0x5d21 {
+		t.Errorf("got frames 0x%04x and 0x%04x", first, second)
+	}
+}
+
+func TestDecodeSharp(t *testing.T) {
+	tests := []struct {
+		pulses []int
+		want   string
+	}{
+		{encodeSharp(0x01, 0x16, sharpExtension["Sharp"]), "Sharp addr=0x01 cmd=0x16"},
+		{encodeSharp(0x02, 0xe1, sharpExtension["Denon"]), "Denon addr=0x02 cmd=0xe1"},
+		// without the inverted frame the code is not valid
+		{encodeSharp(0x01, 0x16, sharpExtension["Sharp"])[:sharpFrameLength/2], "Sharp addr=0x01 cmd=0x16 (invalid checksum)"},
+	}
+
+	for _, test := range tests {
+		if code, ok := decodeSharp(test.pulses); !ok || code.String() != test.want {
+			t.Errorf("got %v, want %v", code, test.want)
+		}
+	}
+
+	// the inverted frame alone is no code
+	if code, ok := decodeSharp(encodeSharp(0x01, 0x16, sharpExtension["Sharp"])[sharpFrameLength/2:]); ok {
+		t.Errorf("got %v", code)
+	}
+}
@@ -0,0 +1,137 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import "fmt"
+
+// SIRC timings in µs, frames start every sircFramePeriod
+const (
+	sircHeaderPulse = 2400
+	sircOnePulse    = 1200
+	sircZeroPulse   = 600
+	sircSpace       = 600
+	sircFramePeriod = 45000
+	// sircMinRepeats are the repeats Sony devices need to accept a code
+	sircMinRepeats = 2
+)
+
+// sircProtocols maps the bit widths of SIRC to the protocol names
+var sircProtocols = map[int]string{12: "SIRC", 15: "SIRC15", 20: "SIRC20"}
+
+// sircProtocol sends at least sircMinRepeats repeats
+var sircProtocol = IRProtocol{
+	Names:  []string{"SIRC", "SIRC15", "SIRC20"},
+	Decode: decodeSIRC,
+	Encode: func(code IRCode) ([]int, int) {
+		bits := map[string]uint{"SIRC": 12, "SIRC15": 15, "SIRC20": 20}[code.Protocol]
+		if code.Repeats < sircMinRepeats {
+			code.Repeats = sircMinRepeats
+		}
+		return encodeSIRC(code.Address, code.Command, code.Extended, bits), code.Repeats
+	},
+	Validate:  validateSIRC,
+	Frequency: 40000,
+}
+
+// sircAddressBits maps the protocol names to the width of their address field, only SIRC20 has the 8 bit extended field
+var sircAddressBits = map[string]uint{"SIRC": 5, "SIRC15": 8, "SIRC20": 5}
+
+// validateSIRC checks that command, address and extended device field fit their fields
+func validateSIRC(code IRCode) error {
+	var extendedBits uint
+	if code.Protocol == "SIRC20" {
+		extendedBits = 8
+	}
+
+	switch {
+	case code.Command > 0x7f:
+		return fmt.Errorf("%v command 0x%02x exceeds 7 bits", code.Protocol, code.Command)
+	case code.Address>>sircAddressBits[code.Protocol] != 0:
+		return fmt.Errorf("%v address 0x%02x exceeds %d bits", code.Protocol, code.Address, sircAddressBits[code.Protocol])
+	case code.Extended>>extendedBits != 0:
+		return fmt.Errorf("%v extended device 0x%02x exceeds %d bits", code.Protocol, code.Extended, extendedBits)
+	}
+
+	return nil
+}
+
+// encodeSIRC encodes one frame of a Sony SIRC code with 12, 15 or 20 bits.
+// The 7 bit command is followed by the 5 bit (8 bit for 15 bits) address, 20 bit codes end with the 8 bit extended device field.
+func encodeSIRC(address uint32, command uint32, extended uint32, bits uint) []int {
+	var train pulseTrain
+	train.add(true, sircHeaderPulse)
+	train.add(false, sircSpace)
+
+	value := uint64(command&0x7f) | uint64(address)<<7
+	if bits == 20 {
+		value = uint64(command&0x7f) | uint64(address&0x1f)<<7 | uint64(extended&0xff)<<12
+	}
+
+	for bit := uint(0); bit < bits; bit++ {
+		if value&(1<<bit) != 0 {
+			train.add(true, sircOnePulse)
+		} else {
+			train.add(true, sircZeroPulse)
+		}
+		train.add(false, sircSpace)
+	}
+
+	train.pad(sircFramePeriod)
+	return train
+}
+
+// decodeSIRC decodes a SIRC code of 12, 15 or 20 bits, identical frames following the first are counted as repeats
+func decodeSIRC(pulses []int) (code IRCode, ok bool) {
+	value, bits, next := decodeSIRCFrame(pulses)
+	protocol, found := sircProtocols[bits]
+	if !found {
+		return code, false
+	}
+
+	code.Protocol = protocol
+	code.Command = value & 0x7f
+	code.Address = value >> 7
+	if bits == 20 {
+		code.Address = value >> 7 & 0x1f
+		code.Extended = value >> 12
+	}
+	code.Valid = true
+
+	for len(pulses) > next+1 {
+		pulses = pulses[next+1:]
+		repeatValue, repeatBits, repeatNext := decodeSIRCFrame(pulses)
+		if repeatValue != value || repeatBits != bits {
+			break
+		}
+
+		code.Repeats++
+		next = repeatNext
+	}
+
+	return code, true
+}
+
+// decodeSIRCFrame decodes the first frame of pulses, next is the index of the space ending the frame
+func decodeSIRCFrame(pulses []int) (value uint32, bits int, next int) {
+	if len(pulses) < 2 || !matchDuration(pulses[0], sircHeaderPulse) || !matchDuration(pulses[1], sircSpace) {
+		return 0, 0, 0
+	}
+
+	for next = 2; next < len(pulses) && bits < 20; next += 2 {
+		switch {
+		case matchDuration(pulses[next], sircOnePulse):
+			value |= 1 << uint(bits)
+		case !matchDuration(pulses[next], sircZeroPulse):
+			return 0, 0, 0
+		}
+		bits++
+
+		// the space of the last bit is part of the gap to the next frame
+		if next+1 >= len(pulses) || !matchDuration(pulses[next+1], sircSpace) {
+			return value, bits, next + 1
+		}
+	}
+
+	return value, bits, next - 1
+}
@@ -0,0 +1,92 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeSIRC(t *testing.T) {
+	// command 0x15 LSB first: 1200 µs pulses for 1, 600 µs for 0
+	pulses := encodeSIRC(0x01, 0x15, 0, 12)
+	if !reflect.DeepEqual(pulses[:8], []int{2400, 600, 1200, 600, 600, 600, 1200, 600}) || len(pulses) != 26 {
+		t.Errorf("got %v", pulses)
+	}
+
+	// a frame lasts 45 ms from header to header
+	if length := pulseTrain(pulses).length(); length != sircFramePeriod {
+		t.Errorf("got frame length %d µs", length)
+	}
+
+	// the extended device field of SIRC20 follows the 5 bit address
+	if pulses := encodeSIRC(0x1a, 0x15, 0x09, 20); len(pulses) != 42 || pulses[26] != sircOnePulse || pulses[28] != sircZeroPulse {
+		t.Errorf("got %v", pulses)
+	}
+}
+
+func TestDecodeSIRC(t *testing.T) {
+	tests := []struct {
+		code IRCode
+		want string
+	}{
+		{IRCode{Protocol: "SIRC", Address: 0x01, Command: 0x15}, "SIRC addr=0x01 cmd=0x15"},
+		{IRCode{Protocol: "SIRC15", Address: 0x97, Command: 0x30}, "SIRC15 addr=0x97 cmd=0x30"},
+		{IRCode{Protocol: "sirc20", Address: 0x1a, Extended: 0x09, Command: 0x15}, "SIRC20 addr=0x1a cmd=0x15 ext=0x09"},
+	}
+
+	for _, test := range tests {
+		packet, err := EncodeIR(test.code)
+		if err != nil {
+			t.Fatalf("%v: %v", test.code, err)
+		}
+
+		// Sony devices need the code three times
+		if code, err := DecodeIR(packet); err != nil || code.String() != test.want || code.Repeats != sircMinRepeats {
+			t.Errorf("%v: got %v with %d repeats (%v), want %v", test.code, code, code.Repeats, err, test.want)
+		}
+	}
+
+	// a learned code has all its frames within the pulses
+	frame := encodeSIRC(0x01, 0x15, 0, 12)
+	pulses := append(append(append([]int(nil), frame...), frame...), frame...)
+	if code, ok := decodeSIRC(pulses); !ok || code.String() != "SIRC addr=0x01 cmd=0x15" || code.Repeats != 2 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+
+	// a different frame ends the repeats
+	pulses = append(append([]int(nil), frame...), encodeSIRC(0x01, 0x16, 0, 12)...)
+	if code, ok := decodeSIRC(pulses); !ok || code.Command != 0x15 || code.Repeats != 0 {
+		t.Errorf("got %v with %d repeats", code, code.Repeats)
+	}
+
+	// 13 bits are no SIRC variant
+	if code, ok := decodeSIRC(encodeSIRC(0x01, 0x15, 0, 13)); ok {
+		t.Errorf("got %v", code)
+	}
+}
+
+func TestEncodeSIRCRange(t *testing.T) {
+	tests := []IRCode{
+		{Protocol: "SIRC", Address: 0x20, Command: 0x15},
+		{Protocol: "SIRC", Address: 0x01, Command: 0x80},
+		{Protocol: "SIRC", Address: 0x01, Command: 0x15, Extended: 0x01},
+		{Protocol: "SIRC15", Address: 0x100, Command: 0x15},
+		{Protocol: "SIRC20", Address: 0x20, Command: 0x15},
+		{Protocol: "SIRC20", Address: 0x1a, Command: 0x15, Extended: 0x100},
+	}
+
+	for _, test := range tests {
+		if _, err := EncodeIR(test); err == nil {
+			t.Errorf("%+v: no error for a value exceeding its field", test)
+		}
+	}
+
+	// the largest values fit
+	for _, code := range []IRCode{{Protocol: "SIRC", Address: 0x1f, Command: 0x7f}, {Protocol: "SIRC15", Address: 0xff, Command: 0x7f}, {Protocol: "SIRC20", Address: 0x1f, Command: 0x7f, Extended: 0xff}} {
+		if _, err := EncodeIR(code); err != nil {
+			t.Errorf("%+v: %v", code, err)
+		}
+	}
+}
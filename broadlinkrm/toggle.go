@@ -0,0 +1,106 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Toggler flips the toggle bit of RC5 and RC6 codes between the sends of a button, so devices see each send as a new press
+type Toggler struct {
+	lock sync.Mutex
+	// State holds the toggle bit last send per protocol, address and command
+	State map[string]bool `yaml:"state"`
+}
+
+// NewToggler creates a toggler with all toggle bits cleared
+func NewToggler() *Toggler {
+	return &Toggler{State: make(map[string]bool)}
+}
+
+// LoadToggler reads the toggle state from a YAML file
+func LoadToggler(path string) (*Toggler, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	toggler := NewToggler()
+	if err = yaml.Unmarshal(data, toggler); err != nil {
+		return nil, fmt.Errorf("toggle state %v: %v", path, err)
+	}
+
+	if toggler.State == nil {
+		toggler.State = make(map[string]bool)
+	}
+
+	return toggler, nil
+}
+
+// Save writes the toggle state to a YAML file
+func (toggler *Toggler) Save(path string) error {
+	toggler.lock.Lock()
+	data, err := yaml.Marshal(toggler)
+	toggler.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Apply returns the packet with the toggle bit flipped since the last send of the same button.
+// The frames within the pulses are encoded again with the flipped toggle bit, the repeat count of the packet is kept.
+// The toggle state only changes if the packet was encoded. Packets of protocols without toggle bit are returned unchanged.
+func (toggler *Toggler) Apply(packet []byte) []byte {
+	parsed, err := ParsePacket(packet)
+	if err != nil || parsed.Kind != PacketIR {
+		return packet
+	}
+
+	code, protocol, err := identifyProtocol(parsed.Pulses)
+	if err != nil {
+		return packet
+	}
+
+	switch code.Protocol {
+	case "RC5", "RC6", "RC6-6-32":
+	default:
+		return packet
+	}
+
+	key := fmt.Sprintf("%v/0x%x/0x%x", code.Protocol, code.Address, code.Command)
+
+	toggler.lock.Lock()
+	defer toggler.lock.Unlock()
+
+	// the code and its repeats decoded from the pulses are frames of their own
+	frames := code.Repeats + 1
+	code.Toggle, code.Repeats = !toggler.State[key], 0
+	frame, _ := protocol.Encode(code)
+
+	var train pulseTrain
+	for i := 0; i < frames; i++ {
+		for j, duration := range frame {
+			train.add(j%2 == 0, duration)
+		}
+	}
+
+	toggled, err := Packet{Kind: parsed.Kind, Repeat: parsed.Repeat, Pulses: train}.Marshal()
+	if err != nil {
+		return packet
+	}
+
+	toggler.State[key] = code.Toggle
+	return toggled
+}
@@ -0,0 +1,72 @@
+package broadlinkrm
+
+/* Copyright (c) 2019, Holger Wolff - All rights reserved.
+   Licenced under BSD 3-Clause License */
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTogglerApply(t *testing.T) {
+	tests := []IRCode{
+		{Protocol: "RC5", Address: 0x05, Command: 0x0c, Repeats: 2},
+		{Protocol: "RC6", Address: 0x04, Command: 0x0c},
+		{Protocol: "RC6-6-32", Address: 0x800f, Command: 0x040c},
+	}
+
+	for _, test := range tests {
+		packet, _ := EncodeIR(test)
+		toggler := NewToggler()
+		for _, toggle := range []bool{true, false, true} {
+			toggled := toggler.Apply(packet)
+			code, err := DecodeIR(toggled)
+			if err != nil {
+				t.Fatalf("%v: %v", test.Protocol, err)
+			}
+
+			if code.Toggle != toggle || code.Address != test.Address || code.Command != test.Command || toggled[1] != packet[1] {
+				t.Errorf("%v: got %v repeated %d times, want toggle %v", test.Protocol, code, toggled[1], toggle)
+			}
+		}
+	}
+
+	// a learned code of two frames sent three times keeps its frames and its repeat count
+	frame, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c})
+	pulses, _ := irPulses(frame)
+	learned, _ := Packet{Kind: PacketIR, Repeat: 2, Pulses: append(append([]int(nil), pulses...), pulses...)}.Marshal()
+	toggled, err := ParsePacket(NewToggler().Apply(learned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := IdentifyProtocol(toggled.Pulses); err != nil || !code.Toggle || code.Repeats != 1 || toggled.Repeat != 2 {
+		t.Errorf("got %v with %d repeats repeated %d times (%v), want toggle, 1 repeat repeated 2 times", code, code.Repeats, toggled.Repeat, err)
+	}
+
+	// codes without toggle bit are send unchanged
+	packet, _ := EncodeIR(IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08, Repeats: 2})
+	if toggled := NewToggler().Apply(packet); !bytes.Equal(toggled, packet) {
+		t.Errorf("NEC code was changed: %x", toggled)
+	}
+}
+
+func TestTogglerSave(t *testing.T) {
+	toggler := NewToggler()
+	packet, _ := EncodeIR(IRCode{Protocol: "RC5", Address: 0x05, Command: 0x0c})
+	toggler.Apply(packet)
+
+	path := filepath.Join(t.TempDir(), "toggle.yaml")
+	if err := toggler.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadToggler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.State, map[string]bool{"RC5/0x5/0xc": true}) {
+		t.Errorf("got state %v", loaded.State)
+	}
+}
@@ -0,0 +1,929 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+func cmdDiscover(args []string) int {
+	fs := newFlagSet("discover")
+	filter := addSelectorFlags(fs)
+	auth := fs.Bool("a", false, "authenticate against each device found")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	sel, err := filter.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	devices := discover(sel)
+	for id, device := range devices {
+		record := newDeviceRecord(device)
+
+		if *auth {
+			err := broadlinkrm.Auth(&device)
+			authenticated := err == nil
+			record.Authenticated = &authenticated
+
+			if err != nil {
+				printMessage(0, fmt.Sprintf("[%02v] Authentication failed: %v \n", id+1, err))
+			}
+		}
+
+		printDevice(id+1, record)
+	}
+
+	printMessage(1, fmt.Sprintf("Found %v device(s)\n", len(devices)))
+	if len(devices) == 0 {
+		return exitNoDevice
+	}
+
+	return exitOK
+}
+
+func cmdInfo(args []string) int {
+	fs := newFlagSet("info")
+	target := addTargetFlags(fs)
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	record := newDeviceRecord(device)
+	emit(0, fmt.Sprintf("Device type: %v \nDevice model: %v \nDevice name: %v \nDevice alias: %v \nDevice MAC: %v \nDevice IP: %v \nDevice locked: %v \n",
+		record.Type, record.Model, record.Name, record.Alias, record.MAC, record.IP, record.Locked), record)
+	return exitOK
+}
+
+func cmdAuth(args []string) int {
+	fs := newFlagSet("auth")
+	target := addTargetFlags(fs)
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	*target.auth = true
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	emit(1, "Device authenticated \n", statusRecord{Device: deviceMAC(device), Status: "authenticated"})
+	return exitOK
+}
+
+func cmdLearn(args []string) int {
+	fs := newFlagSet("learn")
+	target := addTargetFlags(fs)
+	timeout := fs.Uint("timeout", 30, "seconds to wait for a new learned code")
+	save := fs.String("save", "", "save the learned code in the code library as remote/button")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if len(*save) != 0 {
+		if _, _, err := broadlinkrm.SplitCodeName(*save); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	}
+
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	if broadlinkrm.Command(3, nil, &device) == nil {
+		return fail(exitDevice, "device did not enter learning mode")
+	}
+	printMessage(1, "Wait for learned code")
+
+	var learnedCode []byte
+	learned := waitFor(time.Duration(*timeout)*time.Second, func() bool {
+		learnedCode = broadlinkrm.Command(4, nil, &device)
+		return len(learnedCode) != 0
+	})
+	printMessage(1, "\n")
+
+	if !learned {
+		return fail(exitNoCode, "no code learned")
+	}
+
+	return emitLearnedCode("Learned code", learnedCode, device, *save)
+}
+
+func cmdSend(args []string) int {
+	fs := newFlagSet("send")
+	target := addTargetFlags(fs)
+	format := fs.String("format", broadlinkrm.FormatBroadlink, "format of the code [broadlink, pronto, base64] - names like tv/power send a code of the library unless the format is base64")
+	protocol := fs.String("protocol", "", "send a code of this IR protocol [NEC, NECx, Samsung32, JVC, Sharp, Denon, Panasonic, Kaseikyo, RC5, RC6, RC6-6-32, SIRC, SIRC15, SIRC20] instead of a code")
+	address := fs.Uint("address", 0, "address of the code for -protocol")
+	command := fs.Uint("command", 0, "command of the code for -protocol")
+	extended := fs.Uint("extended", 0, "extended device field of SIRC20 codes or vendor of Kaseikyo codes for -protocol")
+	repeats := fs.Int("repeats", 0, "repeat frames following the code for -protocol")
+	repeat := fs.Int("repeat", -1, "set the repeat count of the Broadlink packet [0-255] - IR and RF codes are send 1 + repeat times")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	var code []byte
+	if len(*protocol) != 0 {
+		var err error
+		code, err = broadlinkrm.EncodeIR(broadlinkrm.IRCode{Protocol: *protocol, Address: uint32(*address), Command: uint32(*command), Extended: uint32(*extended), Repeats: *repeats})
+		if err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	} else if *format == "base64" {
+		// base64 codes may contain a slash like the names of library codes
+		var err error
+		if code, err = broadlinkrm.DecodeBase64Code(codeArgument(fs)); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	} else if name := strings.Join(fs.Args(), " "); isCodeName(name) {
+		lib, err := loadLibrary()
+		if err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+
+		libraryCode, err := lib.Get(name)
+		if err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+
+		if code, err = libraryCode.Packet(); err != nil {
+			return fail(exitFailure, "code %v: %v", name, err)
+		}
+	} else {
+		var err error
+		if code, err = hex.DecodeString(codeArgument(fs)); err != nil || len(code) == 0 {
+			return fail(exitUsage, "provided %v IR code is invalid", *format)
+		}
+
+		switch *format {
+		case broadlinkrm.FormatBroadlink:
+		case broadlinkrm.FormatPronto:
+			if code, err = broadlinkrm.Pronto2Broadlink(code); err != nil {
+				return fail(exitUsage, "provided pronto IR code is invalid: %v", err)
+			}
+		default:
+			return fail(exitUsage, "unsupported format %q", *format)
+		}
+	}
+
+	if *repeat >= 0 {
+		var err error
+		if code, err = setRepeat(code, *repeat); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	}
+
+	return sendPacket(target, code)
+}
+
+// setRepeat returns a copy of an IR or RF packet in Broadlink format with another repeat count
+func setRepeat(code []byte, repeat int) ([]byte, error) {
+	if repeat > 0xff {
+		return nil, fmt.Errorf("invalid repeat count %v", repeat)
+	}
+
+	if _, err := broadlinkrm.ParsePacket(code); err != nil {
+		return nil, err
+	}
+
+	code = append([]byte(nil), code...)
+	code[1] = byte(repeat)
+	return code, nil
+}
+
+// sendPacket sends a code over the target device with the toggle bit flipped since the last send
+func sendPacket(target targetFlags, code []byte) int {
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	toggler := loadToggler()
+	code = toggler.Apply(code)
+	if broadlinkrm.Command(2, code, &device) == nil {
+		return fail(exitDevice, "code send failed")
+	}
+
+	saveToggler(toggler)
+
+	emit(1, "code send \n", sendRecord{Device: deviceMAC(device), Code: hex.EncodeToString(code), Sent: true})
+	return exitOK
+}
+
+func cmdAC(args []string) int {
+	fs := newFlagSet("ac")
+	target := addTargetFlags(fs)
+	model := fs.String("model", "", "model of the air conditioner ["+strings.Join(broadlinkrm.ACModels(), ", ")+"]")
+	mode := fs.String("mode", "auto", "mode [auto, cool, heat, dry, fan]")
+	temperature := fs.Int("temperature", 22, "temperature setpoint in °C")
+	fan := fs.String("fan", "auto", "fan speed [auto, low, medium, high]")
+	swing := fs.Bool("swing", false, "swing the air flow")
+	off := fs.Bool("off", false, "switch the air conditioner off")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if len(*model) == 0 {
+		return fail(exitUsage, "no model provided")
+	}
+
+	state := broadlinkrm.ACState{Power: !*off, Mode: *mode, Temperature: *temperature, Fan: *fan, Swing: *swing}
+	code, err := broadlinkrm.EncodeAC(*model, state)
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	return sendPacket(target, code)
+}
+
+func cmdConvert(args []string) int {
+	fs := newFlagSet("convert")
+	from := fs.String("from", "broadlink", "format of the provided code [broadlink, pronto, gc, base64]")
+	to := fs.String("to", "", "format to convert to [broadlink, pronto, gc, base64] - default is pronto for broadlink codes and broadlink for all others")
+	frequency := fs.Uint("frequency", 0, "frequency in Hz of the IR carrier for the Pronto and Global Caché format - default is the frequency of the code, of its IR protocol or of the config file")
+	predefined := fs.Bool("predefined", false, "write RC5, RC6 and NEC codes in the predefined Pronto formats 5000 (RC5), 6000 (RC6) and 900A (NEC) - 5001 is RC5X and not supported")
+	repeat := fs.Int("repeat", -1, "set the repeat count of the Broadlink packet [0-255], e.g. of a RF code")
+	clean := fs.Bool("clean", false, "remove jitter, noise and surplus repeats of a learned code")
+	toProtocol := fs.Bool("to-protocol", false, "decode the code into its IR protocol, address and command instead of converting it")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if *toProtocol {
+		*to = "protocol"
+	}
+
+	if len(*to) == 0 {
+		*to = "broadlink"
+		if *from == "broadlink" {
+			*to = "pronto"
+		}
+	}
+
+	modify := *repeat >= 0 || *clean
+	if *from == *to && (!modify || *to != "broadlink" && *to != "base64") {
+		return fail(exitUsage, "unsupported conversion from %q to %q", *from, *to)
+	}
+
+	record := conversionRecord{From: *from, To: *to}
+	var code []byte
+	switch *from {
+	case "gc":
+		record.Input = strings.Join(fs.Args(), "")
+		var err error
+		if code, err = broadlinkrm.ConvertGlobalCache2Broadlink(record.Input); err != nil {
+			return fail(exitUsage, "provided gc IR code is invalid: %v", err)
+		}
+		record.Frequency = broadlinkrm.GlobalCacheFrequency(record.Input)
+	case "base64":
+		record.Input = codeArgument(fs)
+		var err error
+		if code, err = broadlinkrm.DecodeBase64Code(record.Input); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	case "broadlink", "pronto":
+		var err error
+		code, err = hex.DecodeString(codeArgument(fs))
+		if err != nil || len(code) == 0 {
+			return fail(exitUsage, "provided %v IR code is invalid", *from)
+		}
+
+		record.Input = hex.EncodeToString(code)
+		if *from == "pronto" {
+			record.Frequency = broadlinkrm.ProntoFrequency(code)
+			if code, err = broadlinkrm.Pronto2Broadlink(code); err != nil {
+				return fail(exitUsage, "provided pronto IR code is invalid: %v", err)
+			}
+		}
+	default:
+		return fail(exitUsage, "unsupported conversion from %q to %q", *from, *to)
+	}
+
+	if *repeat >= 0 {
+		var err error
+		if code, err = setRepeat(code, *repeat); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	}
+
+	if *clean {
+		var err error
+		if code, err = broadlinkrm.CleanPacket(code); err != nil {
+			return fail(exitUsage, "%v", err)
+		}
+	}
+
+	// the carrier is taken from the option, the input code, the IR protocol or the config file
+	if *frequency != 0 {
+		record.Frequency = *frequency
+	}
+	if record.Frequency == 0 {
+		record.Frequency = broadlinkrm.InferFrequency(code)
+	}
+	if record.Frequency == 0 {
+		record.Frequency = cfg.Frequency
+	}
+
+	switch *to {
+	case "protocol":
+		ir, err := broadlinkrm.DecodeIR(code)
+		if err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+		record.Output, record.IR = ir.String(), &ir
+		emit(0, fmt.Sprintf("Decoded IR code: %v \n", record.Output), record)
+	case "broadlink":
+		record.Output = hex.EncodeToString(code)
+		emit(0, fmt.Sprintf("Converted IR code in Broadlink format: %v \n", record.Output), record)
+	case "pronto":
+		pronto, err := broadlinkrm.Broadlink2Pronto(code, broadlinkrm.ProntoFrequencyWord(record.Frequency))
+		if err != nil {
+			return fail(exitUsage, "provided %v IR code is invalid: %v", *from, err)
+		}
+		if ir, err := broadlinkrm.DecodeIR(code); err == nil && *predefined {
+			ir.Frequency = record.Frequency
+			if predefinedPronto, err := broadlinkrm.EncodePronto(ir); err == nil {
+				pronto = predefinedPronto
+			}
+		}
+		record.Output = strings.TrimSpace(regexp.MustCompile("(?m)(.{4})").ReplaceAllString(hex.EncodeToString(pronto), "$1 "))
+		emit(0, fmt.Sprintf("Converted IR code in Pronto format (%v Hz): %v \n", record.Frequency, record.Output), record)
+	case "gc":
+		var err error
+		if record.Output, err = broadlinkrm.ConvertBroadlink2GlobalCache(code, record.Frequency); err != nil {
+			return fail(exitUsage, "provided %v IR code is invalid: %v", *from, err)
+		}
+		record.Frequency = broadlinkrm.GlobalCacheFrequency(record.Output)
+		emit(0, fmt.Sprintf("Converted IR code in Global Caché format (%v Hz): %v \n", record.Frequency, record.Output), record)
+	case "base64":
+		record.Output = base64.StdEncoding.EncodeToString(code)
+		emit(0, fmt.Sprintf("Converted IR code in base64 format: %v \n", record.Output), record)
+	default:
+		return fail(exitUsage, "unsupported conversion from %q to %q", *from, *to)
+	}
+
+	return exitOK
+}
+
+func cmdSetup(args []string) int {
+	fs := newFlagSet("setup")
+	deviceIP := fs.String("ip", "", "ip of device - if omitted a broadcast is send")
+	ssid := fs.String("ssid", "", "ssid of wlan for the device setup")
+	password := fs.String("password", "", "password of wlan for the device setup")
+	security := fs.Uint("security", 0, "type of wlan security for the device setup [0-none, 1-wep, 2-wpa1, 3-wpa2]")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if len(*ssid) == 0 {
+		return fail(exitUsage, "no SSID provided")
+	}
+
+	if (*security != 0) && (len(*password) == 0) {
+		return fail(exitUsage, "no WLan password provided")
+	}
+
+	if *security > 3 {
+		return fail(exitUsage, "unsupported WLan security type")
+	}
+
+	ip := net.ParseIP(*deviceIP)
+	if len(*deviceIP) != 0 && ip == nil {
+		return fail(exitUsage, "invalid ip %q", *deviceIP)
+	}
+
+	response := broadlinkrm.Join(*ssid, *password, byte(*security), ip)
+	if response == nil {
+		return fail(exitDevice, "device did not answer")
+	}
+
+	emit(1, fmt.Sprintf("Device returned: [%x] \n", response), statusRecord{Status: "joined", Details: hex.EncodeToString(response)})
+	return exitOK
+}
+
+func cmdSensors(args []string) int {
+	fs := newFlagSet("sensors")
+	target := addTargetFlags(fs)
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	sensors, err := broadlinkrm.ReadSensors(&device)
+	if err != nil {
+		return fail(exitDevice, "reading sensors failed: %v", err)
+	}
+
+	record := sensorsRecord{Device: deviceMAC(device), Temperature: sensors.Temperature}
+	message := fmt.Sprintf("Temperature: %.1f °C \n", sensors.Temperature)
+	if sensors.HasHumidity {
+		record.Humidity = &sensors.Humidity
+		message += fmt.Sprintf("Humidity: %.1f %% \n", sensors.Humidity)
+	}
+
+	emit(0, message, record)
+	return exitOK
+}
+
+func cmdCodes(args []string) int {
+	action := "list"
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "list":
+		return codesList(args)
+	case "show":
+		return codesShow(args)
+	case "add":
+		return codesAdd(args)
+	case "rm":
+		return codesRemove(args)
+	case "last":
+		return codesLast(args)
+	case "import":
+		return codesImport(args)
+	case "export":
+		return codesExport(args)
+	}
+
+	return fail(exitUsage, "unknown codes action %q", action)
+}
+
+func codesList(args []string) int {
+	fs := newFlagSet("codes")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	lib, err := loadLibrary()
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	for _, name := range lib.Names() {
+		code, _ := lib.Get(name)
+		emit(0, name+"\n", libraryCodeRecord{Name: name, Code: *code})
+	}
+
+	return exitOK
+}
+
+func codesShow(args []string) int {
+	fs := newFlagSet("codes")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	lib, err := loadLibrary()
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	name := strings.Join(fs.Args(), " ")
+	code, err := lib.Get(name)
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	message := fmt.Sprintf("Name: %v \nFormat: %v \nData: %v \n", name, code.Format, code.Data)
+	if code.Frequency != 0 {
+		message += fmt.Sprintf("Frequency: %v Hz \n", code.Frequency)
+	}
+	message += fmt.Sprintf("Repeat: %v \n", code.Repeat)
+	if len(code.LearnedFrom) != 0 {
+		message += fmt.Sprintf("Learned from: %v \nLearned: %v \n", code.LearnedFrom, code.Learned.Format(time.RFC3339))
+	}
+
+	record := libraryCodeRecord{Name: name, Code: *code}
+	if packet, err := code.Packet(); err == nil {
+		if parsed, err := broadlinkrm.ParsePacket(packet); err == nil {
+			message += fmt.Sprintf("Type: %v \n", parsed.Kind)
+			record.Type = parsed.Kind.String()
+		}
+		if ir, err := broadlinkrm.DecodeIR(packet); err == nil {
+			message += fmt.Sprintf("Protocol: %v \n", ir)
+			record.IR = &ir
+		}
+	}
+
+	emit(0, message, record)
+	return exitOK
+}
+
+func codesAdd(args []string) int {
+	fs := newFlagSet("codes")
+	format := fs.String("format", broadlinkrm.FormatBroadlink, "format of the code [broadlink, pronto]")
+	frequency := fs.Uint("frequency", 0, "frequency in Hz of the IR carrier - default is the frequency of the pronto code or of the IR protocol")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if fs.NArg() < 2 {
+		return fail(exitUsage, "usage: codes add [options] NAME CODE")
+	}
+
+	name := fs.Arg(0)
+	data, err := hex.DecodeString(strings.Replace(strings.Join(fs.Args()[1:], ""), " ", "", -1))
+	if err != nil || len(data) == 0 {
+		return fail(exitUsage, "provided %v IR code is invalid", *format)
+	}
+
+	code, err := broadlinkrm.NewCode(*format, data)
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+	if *frequency != 0 {
+		code.Frequency = *frequency
+	}
+
+	err = updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		return lib.Set(name, code)
+	})
+	if err != nil {
+		return fail(exitFailure, "saving code failed: %v", err)
+	}
+
+	emit(1, fmt.Sprintf("Code %v saved \n", name), libraryCodeRecord{Name: name, Code: code})
+	return exitOK
+}
+
+func codesRemove(args []string) int {
+	fs := newFlagSet("codes")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	name := strings.Join(fs.Args(), " ")
+	err := updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		return lib.Delete(name)
+	})
+
+	if errors.Is(err, broadlinkrm.ErrCodeNotFound) {
+		return fail(exitUsage, "%v", err)
+	} else if err != nil {
+		return fail(exitFailure, "removing code failed: %v", err)
+	}
+
+	emit(1, fmt.Sprintf("Code %v removed \n", name), statusRecord{Status: "removed", Details: name})
+	return exitOK
+}
+
+func codesLast(args []string) int {
+	fs := newFlagSet("codes")
+	target := addTargetFlags(fs)
+	save := fs.String("save", "", "save the code in the code library as remote/button")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	device, exitCode := target.resolve()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	learnedCode := broadlinkrm.Command(4, nil, &device)
+	if len(learnedCode) == 0 {
+		return fail(exitNoCode, "device holds no learned code")
+	}
+
+	return emitLearnedCode("Device last learned code", learnedCode, device, *save)
+}
+
+func codesImport(args []string) int {
+	fs := newFlagSet("codes")
+	format := fs.String("format", "", "format of the file [lircd, flipper, homeassistant, smartir] - default is selected by the file name")
+	remote := fs.String("remote", "", "remote the codes of a flipper or smartir file are stored in - default is the file name")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if fs.NArg() != 1 {
+		return fail(exitUsage, "usage: codes import [options] FILE")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+	defer file.Close()
+
+	var imported *broadlinkrm.CodeLibrary
+	switch fileFormat(*format, fs.Arg(0)) {
+	case "lircd":
+		imported, err = broadlinkrm.ImportLircConf(file)
+	case "flipper", "smartir":
+		if len(*remote) == 0 {
+			*remote = strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+		}
+
+		if fileFormat(*format, fs.Arg(0)) == "flipper" {
+			imported, err = broadlinkrm.ImportFlipperIR(file, *remote)
+		} else {
+			imported, err = broadlinkrm.ImportSmartIR(file, *remote)
+		}
+	case "homeassistant":
+		imported, err = broadlinkrm.ImportHomeAssistantCodes(file)
+	default:
+		return fail(exitUsage, "unsupported format %q", *format)
+	}
+
+	if err != nil {
+		return fail(exitFailure, "%v: %v", fs.Arg(0), err)
+	}
+
+	err = updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		lib.Merge(imported)
+		return nil
+	})
+	if err != nil {
+		return fail(exitFailure, "saving codes failed: %v", err)
+	}
+
+	for _, name := range imported.Names() {
+		code, _ := imported.Get(name)
+		emit(1, fmt.Sprintf("Code %v imported \n", name), libraryCodeRecord{Name: name, Code: *code})
+	}
+
+	return exitOK
+}
+
+func codesExport(args []string) int {
+	fs := newFlagSet("codes")
+	format := fs.String("format", "", "format of the file [lircd, flipper] - default is flipper for .ir files and lircd for all others")
+	remote := fs.String("remote", "", "export only the codes of this remote - required for flipper files if the library has more than one remote")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if fs.NArg() > 1 {
+		return fail(exitUsage, "usage: codes export [options] [FILE]")
+	}
+
+	lib, err := loadLibrary()
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	if len(*remote) != 0 {
+		selected, found := lib.Remotes[*remote]
+		if !found {
+			return fail(exitUsage, "unknown remote %q", *remote)
+		}
+
+		lib = broadlinkrm.NewCodeLibrary()
+		lib.Remotes[*remote] = selected
+	}
+
+	exportFormat := fileFormat(*format, fs.Arg(0))
+	switch {
+	case exportFormat != "lircd" && exportFormat != "flipper":
+		return fail(exitUsage, "unsupported export format %q", exportFormat)
+	case exportFormat == "flipper" && len(lib.Remotes) != 1:
+		return fail(exitUsage, "a flipper file holds one remote - select it with -remote")
+	}
+
+	out := os.Stdout
+	if fs.NArg() == 1 {
+		if out, err = os.Create(fs.Arg(0)); err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+		defer out.Close()
+	}
+
+	if exportFormat == "flipper" {
+		for _, selected := range lib.Remotes {
+			err = broadlinkrm.ExportFlipperIR(out, selected)
+		}
+	} else {
+		err = broadlinkrm.ExportLircConf(out, lib)
+	}
+
+	if err != nil {
+		return fail(exitFailure, "export failed: %v", err)
+	}
+
+	if fs.NArg() == 1 {
+		emit(1, fmt.Sprintf("Codes exported to %v \n", fs.Arg(0)), statusRecord{Status: "exported", Details: fs.Arg(0)})
+	}
+
+	return exitOK
+}
+
+// fileFormat returns the format of an import or export file, without an explicit format it is selected by the file name:
+// .ir files are Flipper Zero files, .json files SmartIR files and broadlink_remote_*_codes files Home Assistant storage files
+func fileFormat(format string, path string) string {
+	if len(format) != 0 {
+		return format
+	}
+
+	base := filepath.Base(path)
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".ir"):
+		return "flipper"
+	case strings.EqualFold(filepath.Ext(path), ".json"):
+		return "smartir"
+	case strings.HasPrefix(base, "broadlink_remote_") && strings.HasSuffix(base, "_codes"):
+		return "homeassistant"
+	}
+
+	return "lircd"
+}
+
+// emitLearnedCode prints a learned code and saves it in the code library if a name is given
+func emitLearnedCode(title string, learnedCode []byte, device broadlinkrm.Device, name string) int {
+	record := codeRecord{Format: broadlinkrm.FormatBroadlink, Code: hex.EncodeToString(learnedCode)}
+
+	if len(name) != 0 {
+		code := broadlinkrm.NewBroadlinkCode(learnedCode)
+		code.LearnedFrom = newDeviceRecord(device).Alias
+		if len(code.LearnedFrom) == 0 {
+			code.LearnedFrom = deviceMAC(device)
+		}
+		code.Learned = time.Now().UTC()
+
+		err := updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+			return lib.Set(name, code)
+		})
+		if err != nil {
+			return fail(exitFailure, "saving code failed: %v", err)
+		}
+
+		record.Name = name
+	}
+
+	message := fmt.Sprintf("%v: [%x] \n", title, learnedCode)
+	if ir, err := broadlinkrm.DecodeIR(learnedCode); err == nil {
+		message += ir.String() + " \n"
+		record.IR = &ir
+	}
+	if len(name) != 0 {
+		message += fmt.Sprintf("Saved as %v \n", name)
+	}
+
+	emit(0, message, record)
+	return exitOK
+}
+
+func cmdRun(args []string) int {
+	fs := newFlagSet("run")
+	target := addTargetFlags(fs)
+	dryRun := fs.Bool("n", false, "dry run - check the macro without sending codes or waiting")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	macros, err := loadMacros()
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	if fs.NArg() == 0 {
+		for _, name := range macros.Names() {
+			macro := macros.Macros[name]
+			emit(0, fmt.Sprintf("%v \t%v \n", name, macro.Description), macroRecord{Name: name, Description: macro.Description, Steps: len(macro.Steps)})
+		}
+		return exitOK
+	}
+
+	codes, err := loadLibrary()
+	if err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	defaultSelector, err := target.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	exitCode := exitOK
+	devices := make(map[string]*broadlinkrm.Device)
+	runner := broadlinkrm.MacroRunner{
+		Macros:  macros,
+		Codes:   codes,
+		DryRun:  *dryRun,
+		Toggler: loadToggler(),
+		Device: func(name string) (*broadlinkrm.Device, error) {
+			if device, found := devices[name]; found {
+				return device, nil
+			}
+
+			sel := defaultSelector
+			if len(name) != 0 {
+				var err error
+				if sel, err = parseDeviceArgument(name); err != nil {
+					exitCode = exitUsage
+					return nil, err
+				}
+				sel.discoveryTimeout = defaultSelector.discoveryTimeout
+			}
+
+			device, code, err := selectDevice(sel, *target.auth)
+			if err != nil {
+				exitCode = code
+				return nil, err
+			}
+
+			devices[name] = &device
+			return &device, nil
+		},
+	}
+
+	results, err := runner.Run(strings.Join(fs.Args(), " "))
+	if !*dryRun {
+		saveToggler(runner.Toggler)
+	}
+
+	for _, result := range results {
+		record := stepRecord{Macro: result.Macro, Step: result.Step, Action: result.Action, Code: result.Code, Device: result.Device, Duration: result.Duration.Seconds()}
+		message := fmt.Sprintf("[%v #%d] %v %v", result.Macro, result.Step, result.Action, result.Code)
+		if result.Action == "delay" {
+			message = fmt.Sprintf("[%v #%d] delay %v", result.Macro, result.Step, result.Duration)
+		}
+		if len(result.Device) != 0 {
+			message += " via " + result.Device
+		}
+
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+			message += " failed"
+		}
+
+		emit(1, message+" \n", record)
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, broadlinkrm.ErrMacroNotFound) || errors.Is(err, broadlinkrm.ErrCodeNotFound):
+		return fail(exitUsage, "%v", err)
+	case errors.Is(err, broadlinkrm.ErrCommandFailed):
+		return fail(exitDevice, "%v", err)
+	case exitCode != exitOK:
+		return fail(exitCode, "%v", err)
+	}
+
+	return fail(exitFailure, "%v", err)
+}
+
+func printDevice(id int, record deviceRecord) {
+	if outputFormat != outputText {
+		emitRecord(record)
+		return
+	}
+
+	printMessage(2, fmt.Sprintf("[%02v] Device type: %v \n", id, record.Type))
+	printMessage(2, fmt.Sprintf("[%02v] Device model: %v \n", id, record.Model))
+	printMessage(2, fmt.Sprintf("[%02v] Device name: %v \n", id, record.Name))
+	if len(record.Alias) != 0 {
+		printMessage(1, fmt.Sprintf("[%02v] Device alias: %v \n", id, record.Alias))
+	}
+	printMessage(2, fmt.Sprintf("[%02v] Device MAC: %v \n", id, record.MAC))
+	printMessage(2, fmt.Sprintf("[%02v] Device locked: %v \n", id, record.Locked))
+	printMessage(1, fmt.Sprintf("[%02v] Device IP: %v \n", id, record.IP))
+	if record.Authenticated != nil && *record.Authenticated {
+		printMessage(1, fmt.Sprintf("[%02v] Device authenticated \n", id))
+	}
+}
+
+func deviceMAC(device broadlinkrm.Device) string {
+	return net.HardwareAddr(device.DeviceMac()).String()
+}
+
+// waitFor polls until done returns true or the timeout has expired
+func waitFor(timeout time.Duration, done func() bool) bool {
+	endTime := time.Now().Add(timeout)
+	for time.Now().Before(endTime) {
+		if done() {
+			return true
+		}
+		printMessage(1, ".")
+		time.Sleep(1 * time.Second)
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDevice describes a device known by an alias
+type configDevice struct {
+	MAC  string `yaml:"mac"`
+	IP   string `yaml:"ip"`
+	Type string `yaml:"type"`
+}
+
+// config holds the settings read from the configuration file, command line flags override them
+type config struct {
+	// Timeout in seconds to wait for answers of a device
+	Timeout uint `yaml:"timeout"`
+	// DiscoveryTimeout in seconds to wait for answers of a broadcast discovery
+	DiscoveryTimeout uint `yaml:"discovery_timeout"`
+	// Auth against the device before a command is send
+	Auth *bool `yaml:"auth"`
+	// Frequency in Hz of the IR carrier used for conversions if neither the code nor its IR protocol tell it
+	Frequency uint `yaml:"frequency"`
+	// Libraries are the files of the code library
+	Libraries []string `yaml:"libraries"`
+	// Macros are the files of the macro library
+	Macros  []string                `yaml:"macros"`
+	MQTT    configMQTT              `yaml:"mqtt"`
+	Lircd   configLircd             `yaml:"lircd"`
+	Devices map[string]configDevice `yaml:"devices"`
+}
+
+// configMQTT holds the settings of the MQTT bridge
+type configMQTT struct {
+	Broker          string `yaml:"broker"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	Topic           string `yaml:"topic"`
+	DiscoveryPrefix string `yaml:"discovery_prefix"`
+}
+
+// configLircd holds the settings of the lircd socket server
+type configLircd struct {
+	// Device the codes are send with
+	Device string `yaml:"device"`
+	Socket string `yaml:"socket"`
+	Listen string `yaml:"listen"`
+}
+
+// defaultConfig holds the values used if neither the configuration file nor a flag sets them
+var defaultConfig = config{
+	Timeout:          5,
+	DiscoveryTimeout: 5,
+	Frequency:        38000,
+	MQTT: configMQTT{
+		Broker:          "tcp://localhost:1883",
+		Topic:           "broadlink",
+		DiscoveryPrefix: "homeassistant",
+	},
+	Lircd: configLircd{
+		Socket: "/var/run/lirc/lircd",
+		Listen: "127.0.0.1:8765",
+	},
+}
+
+var cfg = defaultConfig
+
+// configPath returns the path of the configuration file, $BROADLINK_CONFIG overrides the default location
+func configPath() string {
+	if path := os.Getenv("BROADLINK_CONFIG"); len(path) != 0 {
+		return path
+	}
+
+	dir := configDir()
+	if len(dir) == 0 {
+		return ""
+	}
+
+	return filepath.Join(dir, "config.yaml")
+}
+
+// configDir returns the directory of the configuration and data files: $XDG_CONFIG_HOME/broadlink or ~/.config/broadlink
+// on every system, empty if the home directory is unknown. os.UserConfigDir is not used as it differs on macOS and Windows.
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "broadlink")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "broadlink")
+}
+
+// loadConfig reads the configuration file on top of the default values.
+// A missing file is no error unless it was named explicitly.
+func loadConfig(path string, explicit bool) (c config, err error) {
+	c = defaultConfig
+	if len(path) == 0 {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return c, nil
+	} else if err != nil {
+		return
+	}
+
+	if err = yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("config file %v: %v", path, err)
+	}
+
+	if c.Timeout == 0 {
+		return c, fmt.Errorf("config file %v: timeout must be at least 1 second", path)
+	}
+
+	if c.Frequency == 0 {
+		return c, fmt.Errorf("config file %v: frequency must be above 0 Hz", path)
+	}
+
+	for i, library := range c.Libraries {
+		c.Libraries[i] = expandPath(library)
+	}
+
+	for i, macros := range c.Macros {
+		c.Macros[i] = expandPath(macros)
+	}
+
+	return
+}
+
+// authDefault returns the default of the authentication flags
+func (c config) authDefault() bool {
+	return c.Auth == nil || *c.Auth
+}
+
+// expandPath replaces a leading ~ with the home directory of the user
+func expandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+// aliasOf returns the alias of the device with the given mac, empty if there is none
+func (c config) aliasOf(mac string) string {
+	for alias, device := range c.Devices {
+		if parsed, err := parseMAC(device.MAC); err == nil && parsed.String() == mac {
+			return alias
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("BROADLINK_CONFIG", "")
+
+	tests := []struct {
+		xdg  string
+		want string
+	}{
+		{"", filepath.Join(home, ".config", "broadlink", "config.yaml")},
+		{"relative", filepath.Join(home, ".config", "broadlink", "config.yaml")},
+		{"/etc/xdg", filepath.Join("/etc/xdg", "broadlink", "config.yaml")},
+	}
+
+	for _, test := range tests {
+		t.Setenv("XDG_CONFIG_HOME", test.xdg)
+		if path := configPath(); path != test.want {
+			t.Errorf("XDG_CONFIG_HOME=%q: got %v, want %v", test.xdg, path, test.want)
+		}
+	}
+
+	t.Setenv("BROADLINK_CONFIG", "/tmp/broadlink.yaml")
+	if path := configPath(); path != "/tmp/broadlink.yaml" {
+		t.Errorf("got %v, want $BROADLINK_CONFIG", path)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	full := write("full.yaml", `
+timeout: 10
+auth: false
+libraries:
+  - ~/codes.yaml
+  - /srv/codes.yaml
+macros:
+  - "~"
+devices:
+  tv:
+    mac: 34ea34010203
+`)
+
+	tests := []struct {
+		path     string
+		explicit bool
+		wantErr  bool
+		check    func(c config) bool
+	}{
+		// the defaults are kept without a file
+		{"", false, false, func(c config) bool { return c.Timeout == 5 && c.Frequency == 38000 }},
+		{filepath.Join(dir, "missing.yaml"), false, false, func(c config) bool { return c.Timeout == 5 && c.MQTT.Broker == defaultConfig.MQTT.Broker }},
+		{filepath.Join(dir, "missing.yaml"), true, true, nil},
+		{full, true, false, func(c config) bool {
+			return c.Timeout == 10 && !c.authDefault() && c.aliasOf("34:ea:34:01:02:03") == "tv" &&
+				c.Libraries[0] == filepath.Join(home, "codes.yaml") && c.Libraries[1] == "/srv/codes.yaml" && c.Macros[0] == home
+		}},
+		// the values missing in the file keep their defaults
+		{full, false, false, func(c config) bool {
+			return c.DiscoveryTimeout == defaultConfig.DiscoveryTimeout && c.Frequency == 38000 &&
+				c.MQTT.Topic == "broadlink" && c.Lircd.Listen == "127.0.0.1:8765"
+		}},
+		{write("partial.yaml", "mqtt:\n  broker: tcp://mqtt:1883\n"), true, false, func(c config) bool {
+			return c.MQTT.Broker == "tcp://mqtt:1883" && c.MQTT.Topic == "broadlink" && c.authDefault()
+		}},
+		{write("timeout.yaml", "timeout: 0\n"), true, true, nil},
+		{write("frequency.yaml", "frequency: 0\n"), true, true, nil},
+		{write("invalid.yaml", "timeout: [\n"), true, true, nil},
+	}
+
+	for _, test := range tests {
+		c, err := loadConfig(test.path, test.explicit)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v (explicit %v): got error %v, want error %v", test.path, test.explicit, err, test.wantErr)
+			continue
+		}
+		if err == nil && !test.check(c) {
+			t.Errorf("%v (explicit %v): got config %+v", test.path, test.explicit, c)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// libraryPaths returns the files of the code library, new codes are stored in the first one
+func libraryPaths() []string {
+	if len(cfg.Libraries) != 0 {
+		return cfg.Libraries
+	}
+
+	return []string{defaultPath("codes.yaml")}
+}
+
+// macroPaths returns the files of the macro library
+func macroPaths() []string {
+	if len(cfg.Macros) != 0 {
+		return cfg.Macros
+	}
+
+	return []string{defaultPath("macros.yaml")}
+}
+
+// defaultPath returns the path of file in the default configuration directory
+func defaultPath(file string) string {
+	dir := configDir()
+	if len(dir) == 0 {
+		return file
+	}
+
+	return filepath.Join(dir, file)
+}
+
+// loadLibrary reads and merges all files of the code library, missing files are skipped
+func loadLibrary() (*broadlinkrm.CodeLibrary, error) {
+	lib := broadlinkrm.NewCodeLibrary()
+	for _, path := range libraryPaths() {
+		file, err := loadLibraryFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		lib.Merge(file)
+	}
+
+	return lib, nil
+}
+
+func loadLibraryFile(path string) (*broadlinkrm.CodeLibrary, error) {
+	lib, err := broadlinkrm.LoadCodeLibrary(path)
+	if os.IsNotExist(err) {
+		return broadlinkrm.NewCodeLibrary(), nil
+	}
+
+	return lib, err
+}
+
+// updateLibrary applies update to the first file of the code library and saves it
+func updateLibrary(update func(lib *broadlinkrm.CodeLibrary) error) error {
+	path := libraryPaths()[0]
+	lib, err := loadLibraryFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err = update(lib); err != nil {
+		return err
+	}
+
+	return lib.Save(path)
+}
+
+// loadMacros reads and merges all files of the macro library, missing files are skipped
+func loadMacros() (*broadlinkrm.MacroLibrary, error) {
+	lib := broadlinkrm.NewMacroLibrary()
+	for _, path := range macroPaths() {
+		file, err := broadlinkrm.LoadMacroLibrary(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		lib.Merge(file)
+	}
+
+	return lib, nil
+}
+
+// toggles holds the toggle bits of RC5 and RC6 codes send by the daemons
+var toggles = broadlinkrm.NewToggler()
+
+// togglePath returns the file the toggle bits of the command line sends are kept in
+func togglePath() string {
+	return filepath.Join(filepath.Dir(libraryPaths()[0]), "toggle.yaml")
+}
+
+// loadToggler reads the toggle bits of the command line sends, a missing file is no error
+func loadToggler() *broadlinkrm.Toggler {
+	toggler, err := broadlinkrm.LoadToggler(togglePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("%v", err)
+		}
+		return broadlinkrm.NewToggler()
+	}
+
+	return toggler
+}
+
+// saveToggler writes the toggle bits of the command line sends, nothing is written before a RC5 or RC6 code was send
+func saveToggler(toggler *broadlinkrm.Toggler) {
+	if len(toggler.State) == 0 {
+		return
+	}
+
+	if err := toggler.Save(togglePath()); err != nil {
+		log.Printf("saving toggle state failed: %v", err)
+	}
+}
+
+// isCodeName reports if value is the name "remote/button" of a code in the library and not a code itself
+func isCodeName(value string) bool {
+	return strings.Contains(value, "/")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// lircdVersion is the version of lircd whose socket protocol is served
+const lircdVersion = "0.10.1"
+
+// lircd serves the lircd socket protocol, the codes are send with one device
+type lircd struct {
+	// send transmits a packet with the device
+	send        func(packet []byte) error
+	libraryLock sync.Mutex
+}
+
+func cmdLircd(args []string) int {
+	fs := newFlagSet("lircd")
+	filter := addSelectorFlags(fs)
+	socket := fs.String("socket", cfg.Lircd.Socket, "path of the unix socket, empty to disable")
+	listen := fs.String("listen", cfg.Lircd.Listen, "tcp address to listen on, empty to disable")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	if len(*filter.device) == 0 {
+		*filter.device = cfg.Lircd.Device
+	}
+
+	if len(*socket) == 0 && len(*listen) == 0 {
+		return fail(exitUsage, "neither -socket nor -listen given")
+	}
+
+	sel, err := filter.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	device, exitCode, err := selectDevice(sel, true)
+	if err != nil {
+		return fail(exitCode, "%v", err)
+	}
+
+	s := &session{device: device}
+	daemon := &lircd{send: func(packet []byte) error {
+		s.Lock()
+		defer s.Unlock()
+		return s.send(packet)
+	}}
+	var listeners []net.Listener
+
+	if len(*socket) != 0 {
+		if err := removeStaleSocket(*socket); err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+
+		listener, err := net.Listen("unix", *socket)
+		if err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+		// clients of the group of the socket may send codes like with lircd
+		os.Chmod(*socket, 0660)
+		listeners = append(listeners, listener)
+	}
+
+	if len(*listen) != 0 {
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return fail(exitFailure, "%v", err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		printMessage(1, fmt.Sprintf("lircd protocol served on %v for device %v \n", listener.Addr(), deviceMAC(device)))
+		go func(listener net.Listener) {
+			errs <- daemon.serve(listener)
+		}(listener)
+	}
+
+	return fail(exitFailure, "%v", <-errs)
+}
+
+// removeStaleSocket removes a socket file left over by a previous run, it would block the listener.
+// Other files and sockets a server still listens on are kept and returned as error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%v exists and is no socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %v is in use by another server", path)
+	}
+
+	return os.Remove(path)
+}
+
+func (daemon *lircd) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go daemon.handle(conn)
+	}
+}
+
+// handle answers the commands of one client, each command is a line
+func (daemon *lircd) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		data, err := daemon.execute(strings.Fields(line))
+		reply := []string{"BEGIN", line}
+		if err != nil {
+			printMessage(2, fmt.Sprintf("lircd %v: %v \n", line, err))
+			reply = append(reply, "ERROR", "DATA", "1", err.Error())
+		} else {
+			reply = append(reply, "SUCCESS")
+			if data != nil {
+				reply = append(reply, "DATA", strconv.Itoa(len(data)))
+				reply = append(reply, data...)
+			}
+		}
+		reply = append(reply, "END")
+
+		if _, err := conn.Write([]byte(strings.Join(reply, "\n") + "\n")); err != nil {
+			log.Printf("lircd client %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// execute runs a command, the returned lines are answered as the data of the reply
+func (daemon *lircd) execute(fields []string) ([]string, error) {
+	switch strings.ToUpper(fields[0]) {
+	case "VERSION":
+		return []string{lircdVersion}, nil
+	case "LIST":
+		return daemon.list(fields[1:])
+	case "SEND_ONCE":
+		return nil, daemon.sendOnce(fields[1:])
+	case "SEND_START", "SEND_STOP", "SIMULATE", "SET_TRANSMITTERS", "SET_INPUTLOG", "DRV_OPTION":
+		return nil, fmt.Errorf("command %v not supported", fields[0])
+	}
+
+	return nil, fmt.Errorf("unknown command: \"%v\"", fields[0])
+}
+
+// list returns the remotes, the buttons of a remote or a single button
+func (daemon *lircd) list(args []string) ([]string, error) {
+	lib, err := daemon.loadLibrary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(args) {
+	case 0:
+		var remotes []string
+		for _, name := range lib.Names() {
+			remote, _, _ := broadlinkrm.SplitCodeName(name)
+			if len(remotes) == 0 || remotes[len(remotes)-1] != remote {
+				remotes = append(remotes, remote)
+			}
+		}
+		return remotes, nil
+	case 1:
+		if _, found := lib.Remotes[args[0]]; !found {
+			return nil, fmt.Errorf("unknown remote: \"%v\"", args[0])
+		}
+
+		var buttons []string
+		for _, name := range lib.Names() {
+			if remote, button, _ := broadlinkrm.SplitCodeName(name); remote == args[0] {
+				buttons = append(buttons, fmt.Sprintf("%016x %v", 0, button))
+			}
+		}
+		return buttons, nil
+	case 2:
+		if _, err := lib.Get(args[0] + "/" + args[1]); err != nil {
+			return nil, fmt.Errorf("unknown command: \"%v\"", args[1])
+		}
+		return []string{fmt.Sprintf("%016x %v", 0, args[1])}, nil
+	}
+
+	return nil, errors.New("bad send packet")
+}
+
+// sendOnce sends the button of a remote, an optional count sets the repeats of the code
+func (daemon *lircd) sendOnce(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errors.New("bad send packet")
+	}
+
+	lib, err := daemon.loadLibrary()
+	if err != nil {
+		return err
+	}
+
+	code, err := lib.Get(args[0] + "/" + args[1])
+	if err != nil {
+		return err
+	}
+
+	packet, err := code.Packet()
+	if err != nil {
+		return fmt.Errorf("code %v/%v: %v", args[0], args[1], err)
+	}
+
+	if len(args) == 3 {
+		repeat, err := strconv.ParseUint(args[2], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid repeat count \"%v\"", args[2])
+		}
+
+		if len(packet) > 1 {
+			packet[1] = byte(repeat)
+		}
+	}
+
+	return daemon.send(packet)
+}
+
+func (daemon *lircd) loadLibrary() (*broadlinkrm.CodeLibrary, error) {
+	daemon.libraryLock.Lock()
+	defer daemon.libraryLock.Unlock()
+	return loadLibrary()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	// unix socket paths are limited to about 100 characters
+	dir, err := os.MkdirTemp("", "lircd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("data"), 0644)
+	if err := removeStaleSocket(file); err == nil {
+		t.Error("no error for a regular file")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+
+	if err := removeStaleSocket(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("error for a missing socket: %v", err)
+	}
+
+	socket := filepath.Join(dir, "lircd")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := removeStaleSocket(socket); err == nil {
+		t.Error("no error for a socket in use")
+	}
+
+	// closing the listener removes the socket, a crashed server leaves it behind
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if err := removeStaleSocket(socket); err != nil {
+		t.Errorf("stale socket: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("stale socket was kept: %v", err)
+	}
+}
+
+func TestLircdProtocol(t *testing.T) {
+	lib := broadlinkrm.NewCodeLibrary()
+	packet, _ := broadlinkrm.EncodeIR(broadlinkrm.IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	for _, name := range []string{"tv/power", "tv/mute", "avr/power"} {
+		lib.Set(name, broadlinkrm.NewBroadlinkCode(packet))
+	}
+	path := filepath.Join(t.TempDir(), "codes.yaml")
+	if err := lib.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Libraries = []string{path}
+
+	var sent [][]byte
+	daemon := &lircd{send: func(packet []byte) error {
+		sent = append(sent, packet)
+		return nil
+	}}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go daemon.handle(server)
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"VERSION", "BEGIN\nVERSION\nSUCCESS\nDATA\n1\n" + lircdVersion + "\nEND\n"},
+		{"LIST", "BEGIN\nLIST\nSUCCESS\nDATA\n2\navr\ntv\nEND\n"},
+		{"LIST tv", "BEGIN\nLIST tv\nSUCCESS\nDATA\n2\n0000000000000000 mute\n0000000000000000 power\nEND\n"},
+		{"LIST tv power", "BEGIN\nLIST tv power\nSUCCESS\nDATA\n1\n0000000000000000 power\nEND\n"},
+		{"LIST vcr", "BEGIN\nLIST vcr\nERROR\nDATA\n1\nunknown remote: \"vcr\"\nEND\n"},
+		{"SEND_ONCE tv power", "BEGIN\nSEND_ONCE tv power\nSUCCESS\nEND\n"},
+		{"SEND_ONCE tv power 3", "BEGIN\nSEND_ONCE tv power 3\nSUCCESS\nEND\n"},
+		{"SEND_ONCE tv volup", "BEGIN\nSEND_ONCE tv volup\nERROR\nDATA\n1\ncode not found: tv/volup\nEND\n"},
+		{"SEND_ONCE tv power x", "BEGIN\nSEND_ONCE tv power x\nERROR\nDATA\n1\ninvalid repeat count \"x\"\nEND\n"},
+		{"SEND_ONCE tv", "BEGIN\nSEND_ONCE tv\nERROR\nDATA\n1\nbad send packet\nEND\n"},
+		{"SEND_START tv power", "BEGIN\nSEND_START tv power\nERROR\nDATA\n1\ncommand SEND_START not supported\nEND\n"},
+		{"FOO", "BEGIN\nFOO\nERROR\nDATA\n1\nunknown command: \"FOO\"\nEND\n"},
+	}
+
+	reader := bufio.NewReader(client)
+	for _, test := range tests {
+		if _, err := client.Write([]byte(test.command + "\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		var reply strings.Builder
+		for !strings.HasSuffix(reply.String(), "\nEND\n") {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%v: %v", test.command, err)
+			}
+			reply.WriteString(line)
+		}
+
+		if reply.String() != test.want {
+			t.Errorf("%v: got %q, want %q", test.command, reply.String(), test.want)
+		}
+	}
+
+	if len(sent) != 2 || sent[0][1] != packet[1] || sent[1][1] != 3 {
+		t.Errorf("got packets %x", sent)
+	}
+}
@@ -1,238 +1,206 @@
 package main
 
 import (
-	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"net"
-	"regexp"
+	"io"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/waringer/broadlink/broadlinkrm"
 )
 
-type cmdArguments struct {
-	cmdConvertBroadlink *string
-	cmdConvertPronto    *string
-	deviceIP            *string
-	cmdSend             *string
-	cmdSendPronto       *string
-	setupPassword       *string
-	setupSSID           *string
-
-	setupSecurity *uint
-
-	cmdAuth       *bool
-	cmdDiscover   *bool
-	cmdLearn      *bool
-	cmdGetLearned *bool
-	cmdQuiet      *bool
-	cmdSetup      *bool
-	cmdVerbose    *bool
+// Exit codes of the command line tool
+const (
+	exitOK       = 0 // command succeeded
+	exitFailure  = 1 // unspecific error
+	exitUsage    = 2 // invalid command line
+	exitNoDevice = 3 // no device or more than one device matched the target
+	exitDevice   = 4 // device did not answer or returned an error
+	exitNoCode   = 5 // no code learned within the timeout
+)
+
+type command struct {
+	name     string
+	synopsis string
+	summary  string
+	run      func(args []string) int
 }
 
-var logLevel = 1
+var (
+	logLevel = 1
+	commands []command
+)
 
-func main() {
-	args := getArguments()
-	checkArguments(args)
+func init() {
+	commands = []command{
+		{"discover", "[options]", "search for devices", cmdDiscover},
+		{"info", "[options]", "show details of a device", cmdInfo},
+		{"auth", "[options]", "authenticate against a device", cmdAuth},
+		{"learn", "[options]", "put a device in learning mode and wait for a new code", cmdLearn},
+		{"send", "[options] CODE | remote/button", "send a code or a code from the library over a device", cmdSend},
+		{"ac", "[options]", "send the state of an air conditioner over a device", cmdAC},
+		{"convert", "[options] CODE", "convert a code between Broadlink, Pronto and Global Caché format", cmdConvert},
+		{"setup", "[options]", "set device wlan settings - device needs to be in AP-Mode for this", cmdSetup},
+		{"sensors", "[options]", "read the temperature and humidity sensors of a device", cmdSensors},
+		{"run", "[options] [MACRO]", "run a macro or list the macros if none is given", cmdRun},
+		{"serve", "[options]", "run a REST API daemon for the devices and the code library", cmdServe},
+		{"mqtt", "[options]", "run an MQTT bridge with Home Assistant discovery for the devices", cmdMQTT},
+		{"lircd", "[options]", "serve the lircd socket protocol to send codes of the library over a device", cmdLircd},
+		{"exporter", "[options]", "serve Prometheus metrics of the devices", cmdExporter},
+		{"codes", "[list | show NAME | add NAME CODE | rm NAME | last | import FILE | export [FILE]] [options]", "manage the code library or get the last learned code from a device", cmdCodes},
+	}
+}
 
-	broadlinkrm.DefaultTimeout = 5
-	broadlinkrm.LogWarnings = *args.cmdVerbose
+func main() {
+	quiet := flag.Bool("q", false, "quiet - only errors may showen")
+	verbose := flag.Bool("v", false, "verbose - show detailed messages")
+	flag.StringVar(&outputFormat, "output", outputText, "output format [text, json, yaml]")
+	configFile := flag.String("config", "", "config file - default is $BROADLINK_CONFIG or $XDG_CONFIG_HOME/broadlink/config.yaml, ~/.config/broadlink/config.yaml without it")
+	timeout := flag.Uint("timeout", 0, "seconds to wait for answers of a device - overrides the config file")
+	library := flag.String("library", "", "file of the code library - overrides the config file")
+	macros := flag.String("macros", "", "file of the macro library - overrides the config file")
+	flag.Usage = usage
+	flag.Parse()
 
-	if *args.cmdVerbose {
-		logLevel++
+	if !checkOutputFormat(outputFormat) {
+		format := outputFormat
+		outputFormat = outputText
+		os.Exit(fail(exitUsage, "unsupported output format %q", format))
 	}
 
-	if *args.cmdQuiet {
-		logLevel = 0
+	var err error
+	if len(*configFile) != 0 {
+		cfg, err = loadConfig(*configFile, true)
+	} else {
+		cfg, err = loadConfig(configPath(), len(os.Getenv("BROADLINK_CONFIG")) != 0)
 	}
 
-	printMessage(1, fmt.Sprintf("Broadlink RM Toolbox\n"))
-
-	convertBroadlink(*args.cmdConvertBroadlink)
-	convertPronto(*args.cmdConvertPronto)
-
-	ip := net.ParseIP(*args.deviceIP)
-
-	if *args.cmdDiscover {
-		dev := discover(ip, *args.cmdAuth)
-		learn(*args.cmdLearn, *args.cmdGetLearned, dev)
-		send(buildIRcommand(*args.cmdSend, *args.cmdSendPronto), dev)
+	if err != nil {
+		os.Exit(fail(exitUsage, "%v", err))
 	}
 
-	if *args.cmdSetup {
-		response := broadlinkrm.Join(*args.setupSSID, *args.setupPassword, byte(*args.setupSecurity), ip)
-		printMessage(1, fmt.Sprintf("Device returned: [%x] \n", response))
+	if *timeout != 0 {
+		cfg.Timeout = *timeout
 	}
-}
 
-func getArguments() (args cmdArguments) {
-	args.cmdAuth = flag.Bool("a", true, "authenticate agaist device")
-	args.cmdConvertBroadlink = flag.String("convertbroadlink", "", "convert code provided in Broadlink format to Pronto format")
-	args.cmdConvertPronto = flag.String("convertpronto", "", "convert code provided in Pronto format to Broadlink format")
-	args.cmdDiscover = flag.Bool("d", false, "discover - search for devices")
-	args.deviceIP = flag.String("ip", "", "ip of device")
-	args.cmdLearn = flag.Bool("learn", false, "put device in learing mode and wait up to 30 seconds for new learned code")
-	args.cmdGetLearned = flag.Bool("learned", false, "get the last learned code from device in Broadlink format")
-	args.cmdQuiet = flag.Bool("q", false, "quiet - only errors may showen")
-	args.cmdSend = flag.String("send", "", "send code provided in Broadlink format")
-	args.cmdSendPronto = flag.String("sendpronto", "", "send code provided in Pronto format")
-
-	args.cmdSetup = flag.Bool("setup", false, "set device wlan settings - device needs to be in AP-Mode for this")
-	args.setupPassword = flag.String("setuppassword", "", "password of wlan for the device setup")
-	args.setupSecurity = flag.Uint("setupsecurity", 0, "type of wlan security for the device setup [0-none, 1-wep, 2-wpa1, 3-wpa2]")
-	args.setupSSID = flag.String("setupssid", "", "ssid of wlan for the device setup")
-
-	args.cmdVerbose = flag.Bool("v", false, "verbose - show detailed messages")
-	flag.Parse()
-	return
-}
-
-func checkArguments(args cmdArguments) {
-	if (*args.cmdLearn || (len(*args.cmdSend) != 0) || (len(*args.cmdSendPronto) != 0) || *args.cmdGetLearned) && !*args.cmdDiscover {
-		log.Fatalln("invalid options - discovery needed")
+	if len(*library) != 0 {
+		cfg.Libraries = []string{*library}
 	}
 
-	if *args.cmdSetup {
-		if len(*args.setupSSID) == 0 {
-			log.Fatalln("No SSID provided")
-		}
-
-		if (*args.setupSecurity != 0) && (len(*args.setupPassword) == 0) {
-			log.Fatalln("No WLan Password provided")
-		}
-
-		if *args.setupSecurity > 3 {
-			log.Fatalln("Unsupported WLan security type")
-		}
+	if len(*macros) != 0 {
+		cfg.Macros = []string{*macros}
 	}
-}
 
-func convertBroadlink(cmdConvertBroadlink string) {
-	if len(cmdConvertBroadlink) != 0 {
-		broadlinkCode, errBroadlink := hex.DecodeString(strings.Replace(cmdConvertBroadlink, " ", "", -1))
+	broadlinkrm.DefaultTimeout = time.Duration(cfg.Timeout)
+	broadlinkrm.LogWarnings = *verbose
 
-		if errBroadlink != nil {
-			log.Fatalln("Provided Broadlink IR code is invalid")
-		}
+	if *verbose {
+		logLevel++
+	}
 
-		printMessage(0, fmt.Sprintf("Converted IR code in Pronto format: %v \n", regexp.MustCompile("(?m)(.{4})").ReplaceAllString(hex.EncodeToString(broadlinkrm.ConvertBroadlink2Pronto(broadlinkCode, 0x6d)), "$1 ")))
+	if *quiet {
+		logLevel = 0
 	}
-}
 
-func convertPronto(cmdConvertPronto string) {
-	if len(cmdConvertPronto) != 0 {
-		prontoCode, errPronto := hex.DecodeString(strings.Replace(cmdConvertPronto, " ", "", -1))
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(exitUsage)
+	}
 
-		if errPronto != nil {
-			log.Fatalln("Provided Pronto IR code is invalid")
+	for _, cmd := range commands {
+		if cmd.name == flag.Arg(0) {
+			printMessage(2, "Broadlink RM Toolbox\n")
+			os.Exit(cmd.run(flag.Args()[1:]))
 		}
-
-		printMessage(0, fmt.Sprintf("Converted IR code in Broadlink format: %x \n", broadlinkrm.ConvertPronto2Broadlink(prontoCode)))
 	}
-}
 
-func discover(ip net.IP, cmdAuth bool) (dev []broadlinkrm.Device) {
-	var devC chan (broadlinkrm.Device)
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+	usage()
+	os.Exit(exitUsage)
+}
 
-	if ip == nil {
-		devC = broadlinkrm.Hello(5, nil)
-	} else {
-		devC = broadlinkrm.Hello(0, ip)
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Broadlink RM Toolbox\n\nusage: %s [-q] [-v] [-output FORMAT] [-config FILE] [-timeout SECONDS] COMMAND [options]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
 
-	id := 0
-	for device := range devC {
-		id++
+	fmt.Fprintf(out, "\nglobal options:\n")
+	flag.PrintDefaults()
+
+	fmt.Fprintf(out, "\nRun '%s COMMAND -h' for the options of a command.\n", os.Args[0])
+	fmt.Fprintf(out, "\nexit codes:\n")
+	fmt.Fprintf(out, "  %d  success\n", exitOK)
+	fmt.Fprintf(out, "  %d  unspecific error\n", exitFailure)
+	fmt.Fprintf(out, "  %d  invalid command line\n", exitUsage)
+	fmt.Fprintf(out, "  %d  no device or more than one device matched the target\n", exitNoDevice)
+	fmt.Fprintf(out, "  %d  device did not answer or returned an error\n", exitDevice)
+	fmt.Fprintf(out, "  %d  no code learned within the timeout\n", exitNoCode)
+}
 
-		printMessage(2, fmt.Sprintf("[%02v] Device type: %X \n", id, device.DeviceType))
-		printMessage(2, fmt.Sprintf("[%02v] Device name: %v \n", id, device.DeviceName))
-		printMessage(2, fmt.Sprintf("[%02v] Device MAC: [% x] \n", id, device.DeviceMac()))
-		printMessage(1, fmt.Sprintf("[%02v] Device IP: %v \n", id, device.DeviceAddr.IP))
+// newFlagSet creates the flag set of a command with a usage message build from the command table
+func newFlagSet(name string) *flag.FlagSet {
+	// parseFlags reports the errors, the usage is only shown for -h
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
 
-		if cmdAuth {
-			broadlinkrm.Auth(&device)
-			printMessage(2, fmt.Sprintf("[%02v] Device authenticated \n", id))
-		}
+	return fs
+}
 
-		dev = append(dev, device)
+// parseFlags parses the options of a command, if ok is false the command ends with exitCode
+func parseFlags(fs *flag.FlagSet, args []string) (exitCode int, ok bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		commandUsage(fs)
+		return exitOK, false
+	} else if err != nil {
+		return fail(exitUsage, "%v - run '%s %s -h' for the options", err, os.Args[0], fs.Name()), false
 	}
 
-	printMessage(1, fmt.Sprintf("Found %v device(s)\n", len(dev)))
-	return
+	return exitOK, true
 }
 
-func learn(cmdLearn bool, cmdGetLearned bool, dev []broadlinkrm.Device) {
-	if cmdLearn {
-		for id, device := range dev {
-			broadlinkrm.Command(3, nil, &device)
-			printMessage(0, fmt.Sprintf("[%02v] Wait for learned code", id))
-
-			var learnedCode []byte
-			startTime := time.Now().Add(30 * time.Second)
-			for time.Now().Before(startTime) {
-				learnedCode = broadlinkrm.Command(4, nil, &device)
-
-				if len(learnedCode) != 0 {
-					printMessage(0, fmt.Sprintf("\n[%02v] Learned code: [%x] \n", id, learnedCode))
-					break
-				}
-				fmt.Print(".")
-				time.Sleep(1 * time.Second)
-			}
-
-			if learnedCode == nil {
-				printMessage(0, fmt.Sprintf("\n[%02v] No code learned! \n", id))
-			}
-		}
-	} else if cmdGetLearned {
-		for id, device := range dev {
-			learnedCode := broadlinkrm.Command(4, nil, &device)
-			printMessage(0, fmt.Sprintf("[%02v] Device last learned code: [%x] \n", id, learnedCode))
+// commandUsage prints the usage and the options of a command
+func commandUsage(fs *flag.FlagSet) {
+	fs.SetOutput(os.Stderr)
+	for _, cmd := range commands {
+		if cmd.name == fs.Name() {
+			fmt.Fprintf(fs.Output(), "usage: %s %s %s\n\n%s\n\noptions:\n", os.Args[0], cmd.name, cmd.synopsis, cmd.summary)
 		}
 	}
+	fs.PrintDefaults()
 }
 
-func buildIRcommand(cmdSend string, cmdSendPronto string) (irCommand []byte) {
-	var err error
-	if len(cmdSend) != 0 {
-		irCommand, err = hex.DecodeString(strings.Replace(cmdSend, " ", "", -1))
-
-		if err != nil {
-			log.Fatalln("Provided Broadlink IR code is invalid")
-		}
-	} else if len(cmdSendPronto) != 0 {
-		irCommand, err = hex.DecodeString(strings.Replace(cmdSendPronto, " ", "", -1))
-
-		if err != nil {
-			log.Fatalln("Provided Pronto IR code is invalid")
-		}
+// codeArgument returns the code given as positional arguments, blanks between the arguments are ignored
+func codeArgument(fs *flag.FlagSet) string {
+	return strings.Replace(strings.Join(fs.Args(), ""), " ", "", -1)
+}
 
-		irCommand = broadlinkrm.ConvertPronto2Broadlink(irCommand)
+func fail(exitCode int, format string, a ...interface{}) int {
+	if outputFormat != outputText {
+		emitRecord(errorRecord{Error: fmt.Sprintf(format, a...), ExitCode: exitCode})
+		return exitCode
 	}
 
-	return
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", a...)
+	return exitCode
 }
 
-func send(irCommand []byte, dev []broadlinkrm.Device) {
-	if irCommand != nil {
-		for id, device := range dev {
-			response := broadlinkrm.Command(2, irCommand, &device)
-
-			if response == nil {
-				printMessage(0, fmt.Sprintf("[%02v] code send failed!\n", id))
-			} else {
-				printMessage(1, fmt.Sprintf("[%02v] code send \n", id))
-			}
-		}
+// printMessage prints message if the log level is high enough, in structured output mode messages go to stderr
+func printMessage(level int, message string) {
+	if logLevel < level {
+		return
 	}
-}
 
-func printMessage(level int, message string) {
-	if logLevel >= level {
-		fmt.Print(message)
+	if outputFormat != outputText {
+		fmt.Fprint(os.Stderr, message)
+		return
 	}
+
+	fmt.Print(message)
 }
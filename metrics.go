@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// latencyBuckets are the upper bounds in seconds of the round-trip latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// deviceMetrics holds the metrics of one device
+type deviceMetrics struct {
+	labels string
+	up     bool
+
+	sensorsChecked bool
+	hasSensors     bool
+	sensors        broadlinkrm.Sensors
+
+	hasPlug   bool
+	plug      broadlinkrm.PlugState
+	energy    float64
+	lastPower time.Time
+
+	commands     uint64
+	timeouts     uint64
+	auths        uint64
+	errors       map[int16]uint64
+	buckets      []uint64
+	latencySum   float64
+	latencyCount uint64
+}
+
+// metrics collects the metrics of all devices and serves them in the Prometheus text format
+type metrics struct {
+	sync.Mutex
+	devices map[string]*deviceMetrics
+}
+
+// newMetrics creates the metrics and hooks them into the exchanges of the library
+func newMetrics() *metrics {
+	m := &metrics{devices: make(map[string]*deviceMetrics)}
+	broadlinkrm.OnExchange = m.record
+	return m
+}
+
+func cmdExporter(args []string) int {
+	fs := newFlagSet("exporter")
+	filter := addSelectorFlags(fs)
+	listen := fs.String("listen", "127.0.0.1:9743", "address the metrics are served on")
+	interval := fs.Duration("interval", time.Minute, "interval to probe the devices and read their sensors")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	sel, err := filter.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	srv := &server{sessions: make(map[string]*session), sel: sel, metrics: newMetrics()}
+	srv.metrics.probe(srv)
+	go func() {
+		for range time.Tick(*interval) {
+			srv.metrics.probe(srv)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", srv.metrics)
+
+	printMessage(1, fmt.Sprintf("Metrics served on %v/metrics \n", *listen))
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	return exitOK
+}
+
+// device returns the metrics of device, they are created on first use. The metrics lock must be held.
+func (m *metrics) device(device broadlinkrm.Device) *deviceMetrics {
+	mac := deviceMAC(device)
+	dm, found := m.devices[mac]
+	if !found {
+		dm = &deviceMetrics{
+			labels:  fmt.Sprintf(`mac="%v",alias="%v",model="%v"`, mac, labelEscaper.Replace(cfg.aliasOf(mac)), labelEscaper.Replace(device.Model())),
+			errors:  make(map[int16]uint64),
+			buckets: make([]uint64, len(latencyBuckets)),
+		}
+		m.devices[mac] = dm
+	}
+
+	return dm
+}
+
+// record counts an exchange with a device, it is called by the library after every Auth and Command
+func (m *metrics) record(exchange broadlinkrm.Exchange) {
+	m.Lock()
+	defer m.Unlock()
+
+	dm := m.device(exchange.Device)
+	if exchange.Packet == 0x65 {
+		dm.auths++
+	} else {
+		dm.commands++
+	}
+
+	if exchange.Timeout {
+		dm.timeouts++
+		return
+	}
+
+	if exchange.ErrorCode != 0 {
+		dm.errors[exchange.ErrorCode]++
+	}
+
+	seconds := exchange.Duration.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			dm.buckets[i]++
+		}
+	}
+	dm.latencySum += seconds
+	dm.latencyCount++
+}
+
+// probe discovers the devices with Hello, marks them up or down and reads the sensors of the reachable ones
+func (m *metrics) probe(srv *server) {
+	found := srv.discover()
+
+	for _, s := range srv.sessionList() {
+		up := found[deviceMAC(s.device)]
+
+		m.Lock()
+		dm := m.device(s.device)
+		dm.up = up
+		checked, hasSensors := dm.sensorsChecked, dm.hasSensors
+		m.Unlock()
+
+		if !up {
+			continue
+		}
+
+		if isPlug(s.device) {
+			m.readPlug(s)
+		} else if !checked || hasSensors {
+			m.readSensors(s)
+		}
+	}
+}
+
+func (m *metrics) readSensors(s *session) {
+	s.Lock()
+	sensors, err := broadlinkrm.ReadSensors(&s.device)
+	s.Unlock()
+
+	m.Lock()
+	defer m.Unlock()
+	dm := m.device(s.device)
+	if !dm.sensorsChecked {
+		dm.sensorsChecked = true
+		dm.hasSensors = err == nil
+	}
+
+	if err == nil {
+		dm.sensors = sensors
+	}
+}
+
+func (m *metrics) readPlug(s *session) {
+	s.Lock()
+	plug, err := broadlinkrm.ReadPlug(&s.device)
+	s.Unlock()
+	if err != nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.device(s.device).updatePlug(plug, time.Now())
+}
+
+// updatePlug stores the state read from a plug at now
+func (dm *deviceMetrics) updatePlug(plug broadlinkrm.PlugState, now time.Time) {
+	// the plugs only report the current power, the energy is estimated from the power between two probes
+	if dm.hasPlug && dm.plug.HasPower && plug.HasPower {
+		dm.energy += (dm.plug.Power + plug.Power) / 2 * now.Sub(dm.lastPower).Hours()
+	}
+
+	dm.hasPlug = true
+	dm.plug = plug
+	dm.lastPower = now
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.Lock()
+	defer m.Unlock()
+
+	var macs []string
+	for mac := range m.devices {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+
+	family := func(name string, kind string, help string, value func(dm *deviceMetrics) []string) {
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+		for _, mac := range macs {
+			for _, line := range value(m.devices[mac]) {
+				io.WriteString(w, name+line+"\n")
+			}
+		}
+	}
+
+	sample := func(dm *deviceMetrics, value interface{}) []string {
+		return []string{fmt.Sprintf("{%v} %v", dm.labels, value)}
+	}
+
+	family("broadlink_up", "gauge", "Whether the device answered the last Hello probe.", func(dm *deviceMetrics) []string {
+		return sample(dm, boolValue(dm.up))
+	})
+	family("broadlink_temperature_celsius", "gauge", "Temperature read from the device sensor.", func(dm *deviceMetrics) []string {
+		if !dm.hasSensors {
+			return nil
+		}
+		return sample(dm, dm.sensors.Temperature)
+	})
+	family("broadlink_humidity_percent", "gauge", "Humidity read from the device sensor.", func(dm *deviceMetrics) []string {
+		if !dm.hasSensors || !dm.sensors.HasHumidity {
+			return nil
+		}
+		return sample(dm, dm.sensors.Humidity)
+	})
+	family("broadlink_plug_on", "gauge", "Whether the smart plug is switched on.", func(dm *deviceMetrics) []string {
+		if !dm.hasPlug {
+			return nil
+		}
+		return sample(dm, boolValue(dm.plug.On))
+	})
+	family("broadlink_plug_power_watts", "gauge", "Current power consumption read from the smart plug.", func(dm *deviceMetrics) []string {
+		if !dm.hasPlug || !dm.plug.HasPower {
+			return nil
+		}
+		return sample(dm, dm.plug.Power)
+	})
+	family("broadlink_plug_energy_watt_hours_total", "counter", "Energy consumed since the exporter started, estimated from the power readings.", func(dm *deviceMetrics) []string {
+		if !dm.hasPlug || !dm.plug.HasPower {
+			return nil
+		}
+		return sample(dm, dm.energy)
+	})
+	family("broadlink_commands_total", "counter", "Commands sent to the device.", func(dm *deviceMetrics) []string {
+		return sample(dm, dm.commands)
+	})
+	family("broadlink_timeouts_total", "counter", "Requests the device did not answer.", func(dm *deviceMetrics) []string {
+		return sample(dm, dm.timeouts)
+	})
+	family("broadlink_device_errors_total", "counter", "Error codes returned by the device.", func(dm *deviceMetrics) (lines []string) {
+		var codes []int
+		for code := range dm.errors {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+
+		for _, code := range codes {
+			lines = append(lines, fmt.Sprintf(`{%v,code="%d"} %v`, dm.labels, code, dm.errors[int16(code)]))
+		}
+		return
+	})
+	family("broadlink_auth_renewals_total", "counter", "Authentications after the first one.", func(dm *deviceMetrics) []string {
+		var renewals uint64
+		if dm.auths > 1 {
+			renewals = dm.auths - 1
+		}
+		return sample(dm, renewals)
+	})
+	family("broadlink_roundtrip_seconds", "histogram", "Round-trip latency of the answered requests.", func(dm *deviceMetrics) (lines []string) {
+		for i, bound := range latencyBuckets {
+			lines = append(lines, fmt.Sprintf(`_bucket{%v,le="%v"} %v`, dm.labels, bound, dm.buckets[i]))
+		}
+		lines = append(lines,
+			fmt.Sprintf(`_bucket{%v,le="+Inf"} %v`, dm.labels, dm.latencyCount),
+			fmt.Sprintf("_sum{%v} %v", dm.labels, dm.latencySum),
+			fmt.Sprintf("_count{%v} %v", dm.labels, dm.latencyCount))
+		return
+	})
+}
+
+// isPlug reports if the device is a smart plug
+func isPlug(device broadlinkrm.Device) bool {
+	return strings.HasPrefix(device.Model(), "SP")
+}
+
+func boolValue(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
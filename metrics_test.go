@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+func TestMetrics(t *testing.T) {
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Devices = map[string]configDevice{"plug": {MAC: "00:00:00:00:00:00"}}
+
+	m := &metrics{devices: make(map[string]*deviceMetrics)}
+	device := broadlinkrm.Device{DeviceType: 0x947a}
+
+	// the durations are exact binary fractions, their sum is printed without rounding
+	exchanges := []broadlinkrm.Exchange{
+		{Device: device, Packet: 0x65, Duration: time.Second / 256},
+		{Device: device, Packet: 0x6a, Command: 1, Duration: time.Second / 128},
+		{Device: device, Packet: 0x6a, Command: 1, Duration: time.Second / 4, ErrorCode: -7},
+		{Device: device, Packet: 0x65, Duration: time.Second / 32},
+		{Device: device, Packet: 0x6a, Command: 1, Duration: 8 * time.Second},
+		{Device: device, Packet: 0x6a, Command: 1, Duration: 5 * time.Second, Timeout: true},
+	}
+	for _, exchange := range exchanges {
+		m.record(exchange)
+	}
+
+	// 100 W and 200 W half an hour apart are 75 Wh
+	start := time.Now()
+	m.device(device).updatePlug(broadlinkrm.PlugState{On: true, Power: 100, HasPower: true}, start)
+	m.device(device).updatePlug(broadlinkrm.PlugState{On: true, Power: 200, HasPower: true}, start.Add(30*time.Minute))
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %v", contentType)
+	}
+
+	labels := `mac="00:00:00:00:00:00",alias="plug",model="SP3S-EU"`
+	want := []string{
+		"# HELP broadlink_up Whether the device answered the last Hello probe.",
+		"# TYPE broadlink_up gauge",
+		"broadlink_up{" + labels + "} 0",
+		"# HELP broadlink_temperature_celsius Temperature read from the device sensor.",
+		"# TYPE broadlink_temperature_celsius gauge",
+		"# HELP broadlink_humidity_percent Humidity read from the device sensor.",
+		"# TYPE broadlink_humidity_percent gauge",
+		"# HELP broadlink_plug_on Whether the smart plug is switched on.",
+		"# TYPE broadlink_plug_on gauge",
+		"broadlink_plug_on{" + labels + "} 1",
+		"# HELP broadlink_plug_power_watts Current power consumption read from the smart plug.",
+		"# TYPE broadlink_plug_power_watts gauge",
+		"broadlink_plug_power_watts{" + labels + "} 200",
+		"# HELP broadlink_plug_energy_watt_hours_total Energy consumed since the exporter started, estimated from the power readings.",
+		"# TYPE broadlink_plug_energy_watt_hours_total counter",
+		"broadlink_plug_energy_watt_hours_total{" + labels + "} 75",
+		"# HELP broadlink_commands_total Commands sent to the device.",
+		"# TYPE broadlink_commands_total counter",
+		"broadlink_commands_total{" + labels + "} 4",
+		"# HELP broadlink_timeouts_total Requests the device did not answer.",
+		"# TYPE broadlink_timeouts_total counter",
+		"broadlink_timeouts_total{" + labels + "} 1",
+		"# HELP broadlink_device_errors_total Error codes returned by the device.",
+		"# TYPE broadlink_device_errors_total counter",
+		"broadlink_device_errors_total{" + labels + `,code="-7"} 1`,
+		"# HELP broadlink_auth_renewals_total Authentications after the first one.",
+		"# TYPE broadlink_auth_renewals_total counter",
+		"broadlink_auth_renewals_total{" + labels + "} 1",
+		"# HELP broadlink_roundtrip_seconds Round-trip latency of the answered requests.",
+		"# TYPE broadlink_roundtrip_seconds histogram",
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.005"} 1`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.01"} 2`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.025"} 2`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.05"} 3`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.1"} 3`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.25"} 4`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="0.5"} 4`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="1"} 4`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="2.5"} 4`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="5"} 4`,
+		"broadlink_roundtrip_seconds_bucket{" + labels + `,le="+Inf"} 5`,
+		"broadlink_roundtrip_seconds_sum{" + labels + "} 8.29296875",
+		"broadlink_roundtrip_seconds_count{" + labels + "} 5",
+	}
+
+	got := strings.Split(strings.TrimSuffix(recorder.Body.String(), "\n"), "\n")
+	for i := 0; i < len(got) || i < len(want); i++ {
+		var gotLine, wantLine string
+		if i < len(got) {
+			gotLine = got[i]
+		}
+		if i < len(want) {
+			wantLine = want[i]
+		}
+		if gotLine != wantLine {
+			t.Errorf("line %d: got %q, want %q", i+1, gotLine, wantLine)
+		}
+	}
+}
+
+func TestMetricsEnergyNeedsPower(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		first  broadlinkrm.PlugState
+		second broadlinkrm.PlugState
+		want   float64
+	}{
+		{broadlinkrm.PlugState{Power: 10, HasPower: true}, broadlinkrm.PlugState{Power: 30, HasPower: true}, 20},
+		{broadlinkrm.PlugState{}, broadlinkrm.PlugState{Power: 30, HasPower: true}, 0},
+		{broadlinkrm.PlugState{Power: 10, HasPower: true}, broadlinkrm.PlugState{}, 0},
+	}
+
+	for _, test := range tests {
+		dm := &deviceMetrics{}
+		dm.updatePlug(test.first, start)
+		dm.updatePlug(test.second, start.Add(time.Hour))
+		if dm.energy != test.want {
+			t.Errorf("%+v then %+v: got %v Wh, want %v Wh", test.first, test.second, dm.energy, test.want)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// bridge publishes the devices on an MQTT broker and executes the commands received for them
+type bridge struct {
+	*server
+	client          mqtt.Client
+	topic           string
+	discoveryPrefix string
+
+	sync.Mutex
+	announced map[string]bool // devices with published discovery configs
+	sensors   map[string]bool // devices with readable sensors
+	humidity  map[string]bool // devices with a humidity sensor
+}
+
+// haDevice describes a device in a Home Assistant discovery config
+type haDevice struct {
+	Identifiers  []string   `json:"identifiers"`
+	Connections  [][]string `json:"connections"`
+	Name         string     `json:"name"`
+	Model        string     `json:"model,omitempty"`
+	Manufacturer string     `json:"manufacturer"`
+}
+
+// haConfig is a Home Assistant MQTT discovery config of an entity
+type haConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	StateTopic        string   `json:"state_topic,omitempty"`
+	CommandTopic      string   `json:"command_topic,omitempty"`
+	PayloadPress      string   `json:"payload_press,omitempty"`
+	PayloadOn         string   `json:"payload_on,omitempty"`
+	PayloadOff        string   `json:"payload_off,omitempty"`
+	ValueTemplate     string   `json:"value_template,omitempty"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	Icon              string   `json:"icon,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+func cmdMQTT(args []string) int {
+	fs := newFlagSet("mqtt")
+	filter := addSelectorFlags(fs)
+	broker := fs.String("broker", cfg.MQTT.Broker, "url of the MQTT broker")
+	username := fs.String("username", cfg.MQTT.Username, "username for the MQTT broker")
+	password := fs.String("password", cfg.MQTT.Password, "password for the MQTT broker")
+	topic := fs.String("topic", cfg.MQTT.Topic, "base topic of the devices")
+	discoveryPrefix := fs.String("discovery", cfg.MQTT.DiscoveryPrefix, "topic prefix for Home Assistant discovery, empty to disable")
+	interval := fs.Duration("interval", time.Minute, "interval to discover devices and read sensors")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	sel, err := filter.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	b := &bridge{
+		server:          &server{sessions: make(map[string]*session), sel: sel},
+		topic:           strings.TrimSuffix(*topic, "/"),
+		discoveryPrefix: strings.TrimSuffix(*discoveryPrefix, "/"),
+		announced:       make(map[string]bool),
+		sensors:         make(map[string]bool),
+		humidity:        make(map[string]bool),
+	}
+
+	hostname, _ := os.Hostname()
+	opts := mqtt.NewClientOptions().
+		AddBroker(*broker).
+		SetClientID("broadlink-"+hostname).
+		SetUsername(*username).
+		SetPassword(*password).
+		SetAutoReconnect(true).
+		SetWill(b.topic+"/bridge/availability", "offline", 1, true).
+		SetOnConnectHandler(b.onConnect)
+
+	b.client = mqtt.NewClient(opts)
+	if token := b.client.Connect(); token.Wait() && token.Error() != nil {
+		return fail(exitFailure, "connecting to %v failed: %v", *broker, token.Error())
+	}
+	printMessage(1, fmt.Sprintf("Connected to MQTT broker %v \n", *broker))
+
+	// the first refresh is done by the connect handler
+	for {
+		time.Sleep(*interval)
+		b.refresh()
+	}
+}
+
+// onConnect subscribes the command topics and publishes the devices again after every (re)connect
+func (b *bridge) onConnect(client mqtt.Client) {
+	b.publish(b.topic+"/bridge/availability", true, "online")
+
+	filters := map[string]byte{
+		b.topic + "/+/send":  1,
+		b.topic + "/+/learn": 1,
+	}
+	if token := client.SubscribeMultiple(filters, b.onMessage); token.Wait() && token.Error() != nil {
+		log.Printf("subscribing command topics failed: %v", token.Error())
+	}
+
+	b.Lock()
+	b.announced = make(map[string]bool)
+	b.Unlock()
+	go b.refresh()
+}
+
+// refresh discovers the devices, publishes their availability and announces new ones
+func (b *bridge) refresh() {
+	found := b.discover()
+
+	for _, s := range b.sessionList() {
+		mac := deviceMAC(s.device)
+		if !found[mac] {
+			b.publish(b.deviceTopic(s.device)+"/availability", true, "offline")
+			continue
+		}
+
+		b.readSensors(s)
+
+		b.Lock()
+		announced := b.announced[mac]
+		b.announced[mac] = true
+		b.Unlock()
+
+		if !announced {
+			b.announce(s)
+		}
+
+		b.publish(b.deviceTopic(s.device)+"/availability", true, "online")
+	}
+}
+
+func (b *bridge) readSensors(s *session) {
+	mac := deviceMAC(s.device)
+
+	b.Lock()
+	hasSensors, checked := b.sensors[mac]
+	b.Unlock()
+	if checked && !hasSensors {
+		return
+	}
+
+	s.Lock()
+	sensors, err := broadlinkrm.ReadSensors(&s.device)
+	s.Unlock()
+
+	if !checked {
+		b.Lock()
+		b.sensors[mac] = err == nil
+		b.humidity[mac] = err == nil && sensors.HasHumidity
+		b.Unlock()
+	}
+
+	if err != nil {
+		return
+	}
+
+	record := sensorsRecord{Device: mac, Temperature: sensors.Temperature}
+	if sensors.HasHumidity {
+		record.Humidity = &sensors.Humidity
+	}
+
+	b.publish(b.deviceTopic(s.device)+"/sensors", false, record)
+}
+
+// announce publishes the device info and the Home Assistant discovery configs of a device
+func (b *bridge) announce(s *session) {
+	record := newDeviceRecord(s.device)
+	base := b.deviceTopic(s.device)
+	b.publish(base+"/info", true, record)
+
+	if len(b.discoveryPrefix) == 0 {
+		return
+	}
+
+	id := "broadlink_" + strings.Replace(record.MAC, ":", "", -1)
+	name := record.Alias
+	if len(name) == 0 {
+		name = record.Name
+	}
+
+	device := haDevice{
+		Identifiers:  []string{id},
+		Connections:  [][]string{{"mac", record.MAC}},
+		Name:         name,
+		Model:        record.Model,
+		Manufacturer: "Broadlink",
+	}
+
+	b.publishConfig("switch", id, "learn", haConfig{
+		Name:         "Learning",
+		StateTopic:   base + "/learn/state",
+		CommandTopic: base + "/learn",
+		PayloadOn:    "ON",
+		PayloadOff:   "OFF",
+		Icon:         "mdi:school",
+		Device:       device,
+	})
+
+	b.Lock()
+	hasSensors, hasHumidity := b.sensors[record.MAC], b.humidity[record.MAC]
+	b.Unlock()
+	if hasSensors {
+		b.publishConfig("sensor", id, "temperature", haConfig{
+			Name:              "Temperature",
+			StateTopic:        base + "/sensors",
+			ValueTemplate:     "{{ value_json.temperature }}",
+			DeviceClass:       "temperature",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "°C",
+			Device:            device,
+		})
+	}
+
+	if hasHumidity {
+		b.publishConfig("sensor", id, "humidity", haConfig{
+			Name:              "Humidity",
+			StateTopic:        base + "/sensors",
+			ValueTemplate:     "{{ value_json.humidity }}",
+			DeviceClass:       "humidity",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "%",
+			Device:            device,
+		})
+	}
+
+	// the buttons of the code library act as remote control of the device. Home Assistant has no MQTT remote
+	// platform, a remote discovery config would be ignored, so each code is published as button entity
+	lib, err := b.loadLibrary()
+	if err != nil {
+		log.Printf("loading code library failed: %v", err)
+		return
+	}
+
+	for _, codeName := range lib.Names() {
+		b.publishConfig("button", id, codeName, haConfig{
+			Name:         strings.Replace(codeName, "/", " ", -1),
+			CommandTopic: base + "/send",
+			PayloadPress: codeName,
+			Icon:         "mdi:remote",
+			Device:       device,
+		})
+	}
+}
+
+func (b *bridge) publishConfig(component string, deviceID string, entity string, config haConfig) {
+	objectID := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(entity))
+
+	config.UniqueID = deviceID + "_" + objectID
+	config.AvailabilityTopic = b.topic + "/" + strings.TrimPrefix(deviceID, "broadlink_") + "/availability"
+	b.publish(fmt.Sprintf("%v/%v/%v/%v/config", b.discoveryPrefix, component, deviceID, objectID), true, config)
+}
+
+// onMessage executes the commands received on the command topics <topic>/<mac>/send and <topic>/<mac>/learn
+func (b *bridge) onMessage(client mqtt.Client, msg mqtt.Message) {
+	parts := strings.Split(msg.Topic(), "/")
+	if len(parts) < 2 {
+		return
+	}
+
+	s, err := b.session(parts[len(parts)-2])
+	if err != nil {
+		log.Printf("%v: %v", msg.Topic(), err)
+		return
+	}
+
+	payload := strings.TrimSpace(string(msg.Payload()))
+	switch parts[len(parts)-1] {
+	case "send":
+		go b.send(s, payload)
+	case "learn":
+		if payload != "OFF" {
+			go b.learn(s, payload)
+		}
+	}
+}
+
+func (b *bridge) send(s *session, payload string) {
+	record := sendRecord{Device: deviceMAC(s.device)}
+
+	packet, err := b.decodePayload(payload)
+	if err == nil {
+		record.Code = hex.EncodeToString(packet)
+		s.Lock()
+		err = s.send(packet)
+		s.Unlock()
+	}
+
+	record.Sent = err == nil
+	if err != nil {
+		record.Error = err.Error()
+		log.Printf("device %v: sending %q failed: %v", record.Device, payload, err)
+	}
+
+	b.publish(b.deviceTopic(s.device)+"/sent", false, record)
+}
+
+// learn puts the device in learning mode, a payload other than "ON" is the name to save the learned code as
+func (b *bridge) learn(s *session, payload string) {
+	name := payload
+	if name == "ON" {
+		name = ""
+	}
+
+	base := b.deviceTopic(s.device)
+	record := learnRecord{Device: deviceMAC(s.device), Status: "idle", Name: name}
+	defer func() {
+		b.publish(base+"/learn/state", true, "OFF")
+		b.publish(base+"/learned", false, record)
+	}()
+
+	if len(name) != 0 {
+		if _, _, err := broadlinkrm.SplitCodeName(name); err != nil {
+			log.Printf("device %v: %v", record.Device, err)
+			return
+		}
+	}
+
+	s.Lock()
+	_, err := s.command(3, nil)
+	s.Unlock()
+	if err != nil {
+		log.Printf("device %v: starting learning failed: %v", record.Device, err)
+		return
+	}
+
+	b.publish(base+"/learn/state", true, "ON")
+
+	var learnedCode []byte
+	endTime := time.Now().Add(30 * time.Second)
+	for len(learnedCode) == 0 && time.Now().Before(endTime) {
+		time.Sleep(1 * time.Second)
+		s.Lock()
+		learnedCode = broadlinkrm.Command(4, nil, &s.device)
+		s.Unlock()
+	}
+
+	if len(learnedCode) == 0 {
+		return
+	}
+
+	record.Status, record.Code = "learned", hex.EncodeToString(learnedCode)
+	if len(name) != 0 {
+		if err := b.saveLearnedCode(s.device, name, learnedCode); err != nil {
+			log.Printf("device %v: saving code failed: %v", record.Device, err)
+			record.Name = ""
+		}
+	}
+}
+
+// decodePayload converts a send payload into a Broadlink packet.
+// The payload is a code in Broadlink or Pronto hex format, the name of a code in the library or a base64 encoded Broadlink packet.
+func (b *bridge) decodePayload(payload string) ([]byte, error) {
+	text := strings.Replace(payload, " ", "", -1)
+	if data, err := hex.DecodeString(text); err == nil && len(data) > 4 {
+		if data[0] == 0 && data[1] == 0 {
+			return broadlinkrm.Pronto2Broadlink(data)
+		}
+		return data, nil
+	}
+
+	if isCodeName(payload) {
+		lib, err := b.loadLibrary()
+		if err != nil {
+			return nil, err
+		}
+
+		if code, err := lib.Get(payload); err == nil {
+			return code.Packet()
+		}
+	}
+
+	if data, err := base64.StdEncoding.DecodeString(text); err == nil && len(data) > 4 {
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("unsupported code %q", payload)
+}
+
+func (b *bridge) deviceTopic(device broadlinkrm.Device) string {
+	return b.topic + "/" + strings.Replace(deviceMAC(device), ":", "", -1)
+}
+
+// publish sends payload to topic, strings are send as they are, all other values JSON encoded
+func (b *bridge) publish(topic string, retained bool, payload interface{}) {
+	data, isString := payload.(string)
+	if !isString {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("encoding message for %v failed: %v", topic, err)
+			return
+		}
+		data = string(encoded)
+	}
+
+	token := b.client.Publish(topic, 1, retained, data)
+	go func() {
+		if token.Wait() && token.Error() != nil {
+			log.Printf("publishing %v failed: %v", topic, token.Error())
+		}
+	}()
+}
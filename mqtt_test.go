@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// testBroker is a minimal MQTT 3.1.1 broker for tests, it delivers with QoS 0 and keeps retained messages
+type testBroker struct {
+	listener net.Listener
+
+	sync.Mutex
+	retained map[string][]byte
+	clients  map[*brokerClient]bool
+}
+
+type brokerClient struct {
+	sync.Mutex
+	conn    net.Conn
+	filters []string
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	broker := &testBroker{listener: listener, retained: make(map[string][]byte), clients: make(map[*brokerClient]bool)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(&brokerClient{conn: conn})
+		}
+	}()
+
+	return broker
+}
+
+func (broker *testBroker) url() string {
+	return "tcp://" + broker.listener.Addr().String()
+}
+
+func (broker *testBroker) serve(client *brokerClient) {
+	defer func() {
+		broker.Lock()
+		delete(broker.clients, client)
+		broker.Unlock()
+		client.conn.Close()
+	}()
+
+	reader := bufio.NewReader(client.conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+
+		length, multiplier := 0, 1
+		for {
+			digit, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			length += int(digit&0x7f) * multiplier
+			multiplier *= 128
+			if digit&0x80 == 0 {
+				break
+			}
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			client.write(0x20, []byte{0, 0})
+			broker.Lock()
+			broker.clients[client] = true
+			broker.Unlock()
+		case 3: // PUBLISH
+			topic, rest := readString(body)
+			qos := header >> 1 & 3
+			if qos > 0 {
+				client.write(0x40, rest[:2])
+				rest = rest[2:]
+			}
+			broker.publish(topic, rest, header&1 != 0)
+		case 8: // SUBSCRIBE
+			id, rest := body[:2], body[2:]
+			granted := []byte{}
+			for len(rest) > 0 {
+				var filter string
+				filter, rest = readString(rest)
+				rest = rest[1:]
+				granted = append(granted, 0)
+
+				client.Lock()
+				client.filters = append(client.filters, filter)
+				client.Unlock()
+			}
+			client.write(0x90, append(id, granted...))
+
+			broker.Lock()
+			for topic, payload := range broker.retained {
+				if client.subscribed(topic) {
+					client.write(0x31, publishBody(topic, payload))
+				}
+			}
+			broker.Unlock()
+		case 10: // UNSUBSCRIBE
+			client.write(0xb0, body[:2])
+		case 12: // PINGREQ
+			client.write(0xd0, nil)
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func (broker *testBroker) publish(topic string, payload []byte, retain bool) {
+	broker.Lock()
+	defer broker.Unlock()
+
+	if retain {
+		if len(payload) == 0 {
+			delete(broker.retained, topic)
+		} else {
+			broker.retained[topic] = payload
+		}
+	}
+
+	for client := range broker.clients {
+		if client.subscribed(topic) {
+			client.write(0x30, publishBody(topic, payload))
+		}
+	}
+}
+
+// retainedMessage returns the retained message of topic
+func (broker *testBroker) retainedMessage(topic string) ([]byte, bool) {
+	broker.Lock()
+	defer broker.Unlock()
+	payload, found := broker.retained[topic]
+	return payload, found
+}
+
+// waitRetained waits until a message on topic is retained, false if none arrives within timeout
+func (broker *testBroker) waitRetained(topic string, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, found := broker.retainedMessage(topic); found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (client *brokerClient) subscribed(topic string) bool {
+	client.Lock()
+	defer client.Unlock()
+	for _, filter := range client.filters {
+		if matchTopic(filter, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (client *brokerClient) write(header byte, body []byte) {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+
+	client.Lock()
+	client.conn.Write(append(packet, body...))
+	client.Unlock()
+}
+
+func readString(data []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(data))
+	return string(data[2 : 2+length]), data[2+length:]
+}
+
+func publishBody(topic string, payload []byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	return append(append(body, topic...), payload...)
+}
+
+// matchTopic reports if topic matches filter with the wildcards + and #
+func matchTopic(filter string, topic string) bool {
+	filterLevels, topicLevels := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"broadlink/+/send", "broadlink/a0b1c2/send", true},
+		{"broadlink/+/send", "broadlink/a0b1c2/learn", false},
+		{"homeassistant/#", "homeassistant/button/x/config", true},
+		{"broadlink/+", "broadlink/a0b1c2/send", false},
+	}
+
+	for _, test := range tests {
+		if got := matchTopic(test.filter, test.topic); got != test.want {
+			t.Errorf("%v %v: got %v, want %v", test.filter, test.topic, got, test.want)
+		}
+	}
+}
+
+func TestBridgeAnnounce(t *testing.T) {
+	broker := newTestBroker(t)
+
+	lib := broadlinkrm.NewCodeLibrary()
+	packet, _ := broadlinkrm.EncodeIR(broadlinkrm.IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	lib.Set("tv/power", broadlinkrm.NewBroadlinkCode(packet))
+	path := filepath.Join(t.TempDir(), "codes.yaml")
+	if err := lib.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Libraries = []string{path}
+
+	b := &bridge{
+		server:          &server{sessions: make(map[string]*session)},
+		topic:           "broadlink",
+		discoveryPrefix: "homeassistant",
+		announced:       make(map[string]bool),
+		sensors:         make(map[string]bool),
+		humidity:        make(map[string]bool),
+	}
+	b.client = mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker.url()).SetClientID("bridge"))
+	if token := b.client.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer b.client.Disconnect(0)
+
+	device := broadlinkrm.Device{DeviceType: 0x2712, DeviceName: "RM2", DeviceAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}}
+	b.announce(&session{device: device})
+
+	// a second client receives the retained discovery configs like Home Assistant does
+	received := make(chan mqtt.Message, 10)
+	listener := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker.url()).SetClientID("homeassistant"))
+	if token := listener.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer listener.Disconnect(0)
+
+	// the bridge publishes asynchronously, wait for its last config
+	if !broker.waitRetained("homeassistant/button/broadlink_000000000000/tv_power/config", 5*time.Second) {
+		t.Fatal("no discovery config of the code published")
+	}
+
+	token := listener.Subscribe("homeassistant/#", 1, func(client mqtt.Client, msg mqtt.Message) {
+		received <- msg
+	})
+	if token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+
+	configs := map[string]haConfig{}
+	for len(configs) < 2 {
+		select {
+		case msg := <-received:
+			var config haConfig
+			if err := json.Unmarshal(msg.Payload(), &config); err != nil {
+				t.Fatalf("%v: %v", msg.Topic(), err)
+			}
+			if !msg.Retained() {
+				t.Errorf("%v: config is not retained", msg.Topic())
+			}
+			configs[msg.Topic()] = config
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got configs %v, want the learn switch and the code button", configs)
+		}
+	}
+
+	button := configs["homeassistant/button/broadlink_000000000000/tv_power/config"]
+	if button.CommandTopic != "broadlink/000000000000/send" || button.PayloadPress != "tv/power" || button.Device.Identifiers[0] != "broadlink_000000000000" {
+		t.Errorf("got button config %+v", button)
+	}
+
+	learn := configs["homeassistant/switch/broadlink_000000000000/learn/config"]
+	if learn.CommandTopic != "broadlink/000000000000/learn" || learn.AvailabilityTopic != "broadlink/000000000000/availability" {
+		t.Errorf("got learn switch config %+v", learn)
+	}
+
+	if info, found := broker.retainedMessage("broadlink/000000000000/info"); !found || !strings.Contains(string(info), `"mac":"00:00:00:00:00:00"`) {
+		t.Errorf("got device info %s", info)
+	}
+}
+
+func TestBridgeDecodePayload(t *testing.T) {
+	packet, _ := broadlinkrm.EncodeIR(broadlinkrm.IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	b := &bridge{server: &server{}}
+
+	tests := map[string]string{
+		"hex":    strings.ToUpper(hex.EncodeToString(packet)),
+		"base64": base64.StdEncoding.EncodeToString(packet),
+	}
+	for name, payload := range tests {
+		if data, err := b.decodePayload(payload); err != nil || !bytes.Equal(data, packet) {
+			t.Errorf("%v: got %x (%v), want %x", name, data, err, packet)
+		}
+	}
+
+	if data, err := b.decodePayload("0000 006d 0001 0001 0157 00ab 0157 00ab"); err != nil || data[0] != 0x26 {
+		t.Errorf("pronto: got %x (%v)", data, err)
+	}
+	if _, err := b.decodePayload("power"); err == nil {
+		t.Error("no error for an unsupported payload")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the command line tool
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var outputFormat = outputText
+
+type deviceRecord struct {
+	Type          string `json:"type" yaml:"type"`
+	Model         string `json:"model" yaml:"model"`
+	MAC           string `json:"mac" yaml:"mac"`
+	IP            string `json:"ip" yaml:"ip"`
+	Name          string `json:"name" yaml:"name"`
+	Alias         string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Locked        bool   `json:"lock" yaml:"lock"`
+	Authenticated *bool  `json:"authenticated,omitempty" yaml:"authenticated,omitempty"`
+}
+
+type codeRecord struct {
+	Name   string              `json:"name,omitempty" yaml:"name,omitempty"`
+	Format string              `json:"format" yaml:"format"`
+	Code   string              `json:"code" yaml:"code"`
+	IR     *broadlinkrm.IRCode `json:"ir,omitempty" yaml:"ir,omitempty"`
+}
+
+type libraryCodeRecord struct {
+	Name             string `json:"name" yaml:"name"`
+	broadlinkrm.Code `yaml:",inline"`
+	Type             string              `json:"type,omitempty" yaml:"type,omitempty"`
+	IR               *broadlinkrm.IRCode `json:"ir,omitempty" yaml:"ir,omitempty"`
+}
+
+type conversionRecord struct {
+	From      string              `json:"from" yaml:"from"`
+	To        string              `json:"to" yaml:"to"`
+	Input     string              `json:"input" yaml:"input"`
+	Output    string              `json:"output" yaml:"output"`
+	Frequency uint                `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	IR        *broadlinkrm.IRCode `json:"ir,omitempty" yaml:"ir,omitempty"`
+}
+
+type sendRecord struct {
+	Device string `json:"device" yaml:"device"`
+	Code   string `json:"code" yaml:"code"`
+	Sent   bool   `json:"sent" yaml:"sent"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type sensorsRecord struct {
+	Device      string   `json:"device" yaml:"device"`
+	Temperature float64  `json:"temperature" yaml:"temperature"`
+	Humidity    *float64 `json:"humidity,omitempty" yaml:"humidity,omitempty"`
+}
+
+type statusRecord struct {
+	Device  string `json:"device,omitempty" yaml:"device,omitempty"`
+	Status  string `json:"status" yaml:"status"`
+	Details string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+type macroRecord struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       int    `json:"steps" yaml:"steps"`
+}
+
+type stepRecord struct {
+	Macro    string  `json:"macro" yaml:"macro"`
+	Step     int     `json:"step" yaml:"step"`
+	Action   string  `json:"action" yaml:"action"`
+	Code     string  `json:"code,omitempty" yaml:"code,omitempty"`
+	Device   string  `json:"device,omitempty" yaml:"device,omitempty"`
+	Duration float64 `json:"duration" yaml:"duration"`
+	Error    string  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type errorRecord struct {
+	Error    string `json:"error" yaml:"error"`
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+}
+
+func newDeviceRecord(device broadlinkrm.Device) deviceRecord {
+	mac := deviceMAC(device)
+	return deviceRecord{
+		Type:   fmt.Sprintf("0x%04x", device.DeviceType),
+		Model:  device.Model(),
+		MAC:    mac,
+		IP:     device.DeviceAddr.IP.String(),
+		Name:   device.DeviceName,
+		Alias:  cfg.aliasOf(mac),
+		Locked: device.Locked,
+	}
+}
+
+func checkOutputFormat(format string) bool {
+	switch format {
+	case outputText, outputJSON, outputYAML:
+		return true
+	}
+
+	return false
+}
+
+// emit prints record in the selected structured output format, in text mode message is printed with the given level
+func emit(level int, message string, record interface{}) {
+	if outputFormat == outputText {
+		printMessage(level, message)
+		return
+	}
+
+	emitRecord(record)
+}
+
+func emitRecord(record interface{}) {
+	switch outputFormat {
+	case outputJSON:
+		json.NewEncoder(os.Stdout).Encode(record)
+	case outputYAML:
+		out, _ := yaml.Marshal(record)
+		fmt.Printf("---\n%s", out)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// captureStdout returns what run writes to stdout in the given output format
+func captureStdout(t *testing.T, format string, run func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedStdout, savedFormat, savedLevel := os.Stdout, outputFormat, logLevel
+	os.Stdout, outputFormat, logLevel = writer, format, 1
+	defer func() { os.Stdout, outputFormat, logLevel = savedStdout, savedFormat, savedLevel }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	run()
+	writer.Close()
+	return <-output
+}
+
+func TestCheckOutputFormat(t *testing.T) {
+	tests := map[string]bool{"text": true, "json": true, "yaml": true, "xml": false, "JSON": false, "": false}
+	for format, want := range tests {
+		if got := checkOutputFormat(format); got != want {
+			t.Errorf("%q: got %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestEmit(t *testing.T) {
+	record := statusRecord{Device: "tv", Status: "ok"}
+
+	if got := captureStdout(t, outputText, func() { emit(1, "sent\n", record) }); got != "sent\n" {
+		t.Errorf("text: got %q, want %q", got, "sent\n")
+	}
+
+	if got := captureStdout(t, outputText, func() { emit(2, "details\n", record) }); got != "" {
+		t.Errorf("text above log level: got %q, want nothing", got)
+	}
+
+	if got := captureStdout(t, outputJSON, func() { emit(1, "sent\n", record) }); got != `{"device":"tv","status":"ok"}`+"\n" {
+		t.Errorf("json: got %q", got)
+	}
+
+	if got := captureStdout(t, outputYAML, func() { emit(1, "sent\n", record) }); got != "---\ndevice: tv\nstatus: ok\n" {
+		t.Errorf("yaml: got %q", got)
+	}
+}
+
+func TestFailStructured(t *testing.T) {
+	var exitCode int
+	got := captureStdout(t, outputJSON, func() { exitCode = fail(exitNoDevice, "no device %v", "tv") })
+
+	var record errorRecord
+	if err := json.Unmarshal([]byte(got), &record); err != nil {
+		t.Fatalf("%q: %v", got, err)
+	}
+	if exitCode != exitNoDevice || record != (errorRecord{Error: "no device tv", ExitCode: exitNoDevice}) {
+		t.Errorf("got %+v with exit code %v", record, exitCode)
+	}
+}
+
+func TestParseFlagsStructured(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+		ok   bool
+	}{
+		{[]string{"-to", "pronto", "2600"}, exitOK, true},
+		{[]string{"-unknown"}, exitUsage, false},
+		{[]string{"-repeat", "many"}, exitUsage, false},
+	}
+
+	for _, test := range tests {
+		var exitCode int
+		var ok bool
+		got := captureStdout(t, outputJSON, func() {
+			fs := newFlagSet("convert")
+			fs.String("to", "", "")
+			fs.Int("repeat", -1, "")
+			exitCode, ok = parseFlags(fs, test.args)
+		})
+
+		if exitCode != test.want || ok != test.ok {
+			t.Errorf("%v: got exit code %v (ok %v), want %v (ok %v)", test.args, exitCode, ok, test.want, test.ok)
+		}
+		if !test.ok && !strings.Contains(got, `"exit_code":2`) {
+			t.Errorf("%v: got output %q", test.args, got)
+		}
+	}
+}
+
+func TestConvertOutput(t *testing.T) {
+	var exitCode int
+	got := captureStdout(t, outputYAML, func() {
+		exitCode = cmdConvert([]string{"-from", "pronto", "0000 006D 0000 0002", "0157 00AB 0016 0F00"})
+	})
+	if exitCode != exitOK {
+		t.Fatalf("exit code %v, output %q", exitCode, got)
+	}
+
+	var record conversionRecord
+	if err := yaml.Unmarshal([]byte(got), &record); err != nil {
+		t.Fatalf("%q: %v", got, err)
+	}
+	if record.From != "pronto" || record.To != "broadlink" || record.Input != "0000006d00000002015700ab00160f00" || !strings.HasPrefix(record.Output, "26") {
+		t.Errorf("got %+v", record)
+	}
+
+	// the default target of a Broadlink code is Pronto
+	got = captureStdout(t, outputJSON, func() { exitCode = cmdConvert([]string{record.Output}) })
+	if err := json.Unmarshal([]byte(got), &record); err != nil || exitCode != exitOK {
+		t.Fatalf("%q: %v", got, err)
+	}
+	if record.From != "broadlink" || record.To != "pronto" || !strings.HasPrefix(record.Output, "0000 006d") {
+		t.Errorf("got %+v", record)
+	}
+
+	got = captureStdout(t, outputJSON, func() { exitCode = cmdConvert([]string{"-from", "pronto", "-to", "pronto", "0000"}) })
+	if exitCode != exitUsage || !strings.Contains(got, `"exit_code":2`) {
+		t.Errorf("same format: got exit code %v, output %q", exitCode, got)
+	}
+}
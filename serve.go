@@ -0,0 +1,560 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// session holds an authenticated device, operations on the device are serialised by the session lock
+type session struct {
+	sync.Mutex
+	device    broadlinkrm.Device
+	learning  bool
+	learnSave string
+}
+
+// server is the REST API daemon, it keeps one authenticated session per device
+type server struct {
+	sync.RWMutex
+	sessions    map[string]*session
+	sel         selector
+	libraryLock sync.Mutex
+	metrics     *metrics
+}
+
+// statusError is an error with the HTTP status code to answer with
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string { return e.err.Error() }
+func (e statusError) Unwrap() error { return e.err }
+
+type sendRequest struct {
+	Name   string `json:"name"`
+	Code   string `json:"code"`
+	Format string `json:"format"`
+}
+
+type learnRequest struct {
+	Save string `json:"save"`
+}
+
+type learnRecord struct {
+	Device string `json:"device"`
+	Status string `json:"status"`
+	Code   string `json:"code,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+func cmdServe(args []string) int {
+	fs := newFlagSet("serve")
+	filter := addSelectorFlags(fs)
+	listen := fs.String("listen", "127.0.0.1:8080", "address the REST API listens on - the API has no authentication, listen on other interfaces only in trusted networks")
+	refresh := fs.Duration("refresh", 5*time.Minute, "interval to discover new devices and update the metrics, 0 to disable")
+	if exitCode, ok := parseFlags(fs, args); !ok {
+		return exitCode
+	}
+
+	sel, err := filter.selector()
+	if err != nil {
+		return fail(exitUsage, "%v", err)
+	}
+
+	srv := &server{sessions: make(map[string]*session), sel: sel, metrics: newMetrics()}
+	srv.metrics.probe(srv)
+	if *refresh > 0 {
+		go func() {
+			for range time.Tick(*refresh) {
+				srv.metrics.probe(srv)
+			}
+		}()
+	}
+
+	printMessage(1, fmt.Sprintf("REST API listening on %v \n", *listen))
+	if err := http.ListenAndServe(*listen, srv.routes()); err != nil {
+		return fail(exitFailure, "%v", err)
+	}
+
+	return exitOK
+}
+
+func (srv *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices", srv.handle(srv.listDevices))
+	mux.HandleFunc("POST /devices/discover", srv.handle(srv.rediscover))
+	mux.HandleFunc("GET /devices/{id}", srv.handle(srv.getDevice))
+	mux.HandleFunc("POST /devices/{id}/send", srv.handle(srv.send))
+	mux.HandleFunc("POST /devices/{id}/learn", srv.handle(srv.startLearning))
+	mux.HandleFunc("GET /devices/{id}/learn", srv.handle(srv.pollLearning))
+	mux.HandleFunc("GET /devices/{id}/sensors", srv.handle(srv.sensors))
+	mux.HandleFunc("GET /codes", srv.handle(srv.listCodes))
+	mux.HandleFunc("GET /codes/{remote}/{button}", srv.handle(srv.getCode))
+	mux.HandleFunc("PUT /codes/{remote}/{button}", srv.handle(srv.putCode))
+	mux.HandleFunc("DELETE /codes/{remote}/{button}", srv.handle(srv.deleteCode))
+	mux.HandleFunc("POST /macros/{name}/run", srv.handle(srv.runMacro))
+	mux.Handle("GET /metrics", srv.metrics)
+	return mux
+}
+
+// handle wraps an API function, its result is answered as JSON and its error with the mapped status code.
+// A result returned together with an error is answered along with the error, e.g. the steps of a failed macro.
+func (srv *server) handle(api func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		result, err := api(r)
+		if err != nil {
+			status := errorStatus(err)
+			printMessage(2, fmt.Sprintf("%v %v: %d %v \n", r.Method, r.URL.Path, status, err))
+			w.WriteHeader(status)
+			answer := map[string]interface{}{"error": err.Error()}
+			if result != nil {
+				answer["result"] = result
+			}
+			json.NewEncoder(w).Encode(answer)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// errorStatus maps an error to the HTTP status code to answer with
+func errorStatus(err error) int {
+	var statusErr statusError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.status
+	case errors.Is(err, broadlinkrm.ErrCodeNotFound), errors.Is(err, broadlinkrm.ErrMacroNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, broadlinkrm.ErrNoResponse):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, broadlinkrm.ErrCommandFailed):
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return statusError{http.StatusBadRequest, fmt.Errorf(format, a...)}
+}
+
+// discover searches for devices and opens a session for each new one, the macs of all found devices are returned
+func (srv *server) discover() (found map[string]bool) {
+	found = make(map[string]bool)
+	for _, device := range discover(srv.sel) {
+		mac := deviceMAC(device)
+		found[mac] = true
+
+		srv.RLock()
+		_, known := srv.sessions[mac]
+		srv.RUnlock()
+		if known {
+			continue
+		}
+
+		if err := broadlinkrm.Auth(&device); err != nil {
+			log.Printf("device %v: authentication failed: %v", mac, err)
+			continue
+		}
+
+		srv.Lock()
+		srv.sessions[mac] = &session{device: device}
+		srv.Unlock()
+		printMessage(1, fmt.Sprintf("Device %v (%v) at %v ready \n", mac, device.Model(), device.DeviceAddr.IP))
+	}
+
+	return
+}
+
+// session returns the session of the device with the mac or alias id
+func (srv *server) session(id string) (*session, error) {
+	if device, found := cfg.Devices[id]; found {
+		id = device.MAC
+	}
+
+	mac, err := parseMAC(id)
+	if err != nil {
+		return nil, statusError{http.StatusNotFound, fmt.Errorf("unknown device %q", id)}
+	}
+
+	srv.RLock()
+	defer srv.RUnlock()
+	if s, found := srv.sessions[mac.String()]; found {
+		return s, nil
+	}
+
+	return nil, statusError{http.StatusNotFound, fmt.Errorf("unknown device %q", id)}
+}
+
+// send transmits a packet with the device of the session, the toggle bit of RC5 and RC6 codes is flipped between sends
+func (s *session) send(packet []byte) error {
+	_, err := s.command(2, toggles.Apply(packet))
+	return err
+}
+
+// command sends a command to the device of the session, the session is renewed once if the command fails
+func (s *session) command(cmd uint32, data []byte) ([]byte, error) {
+	if response := broadlinkrm.Command(cmd, data, &s.device); response != nil {
+		return response, nil
+	}
+
+	if err := broadlinkrm.Auth(&s.device); err != nil {
+		return nil, err
+	}
+
+	if response := broadlinkrm.Command(cmd, data, &s.device); response != nil {
+		return response, nil
+	}
+
+	return nil, broadlinkrm.ErrCommandFailed
+}
+
+func (srv *server) listDevices(r *http.Request) (interface{}, error) {
+	srv.RLock()
+	defer srv.RUnlock()
+
+	records := []deviceRecord{}
+	for _, s := range srv.sessions {
+		records = append(records, newDeviceRecord(s.device))
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].MAC < records[j].MAC })
+	return records, nil
+}
+
+func (srv *server) sessionList() (sessions []*session) {
+	srv.RLock()
+	defer srv.RUnlock()
+	for _, s := range srv.sessions {
+		sessions = append(sessions, s)
+	}
+
+	return
+}
+
+func (srv *server) rediscover(r *http.Request) (interface{}, error) {
+	srv.discover()
+	return srv.listDevices(r)
+}
+
+func (srv *server) getDevice(r *http.Request) (interface{}, error) {
+	s, err := srv.session(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeviceRecord(s.device), nil
+}
+
+func (srv *server) send(r *http.Request) (interface{}, error) {
+	s, err := srv.session(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	var request sendRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, badRequest("invalid request: %v", err)
+	}
+
+	var packet []byte
+	switch {
+	case len(request.Name) != 0:
+		lib, err := srv.loadLibrary()
+		if err != nil {
+			return nil, err
+		}
+
+		code, err := lib.Get(request.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if packet, err = code.Packet(); err != nil {
+			return nil, err
+		}
+	case len(request.Code) != 0:
+		data, err := hex.DecodeString(strings.Replace(request.Code, " ", "", -1))
+		if err != nil {
+			return nil, badRequest("invalid code: %v", err)
+		}
+
+		code, err := broadlinkrm.NewCode(request.Format, data)
+		if err != nil {
+			return nil, badRequest("%v", err)
+		}
+		if packet, err = code.Packet(); err != nil {
+			return nil, badRequest("%v", err)
+		}
+	default:
+		return nil, badRequest("neither name nor code given")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if err := s.send(packet); err != nil {
+		return nil, err
+	}
+
+	return sendRecord{Device: deviceMAC(s.device), Code: hex.EncodeToString(packet), Sent: true}, nil
+}
+
+func (srv *server) startLearning(r *http.Request) (interface{}, error) {
+	s, err := srv.session(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	var request learnRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return nil, badRequest("invalid request: %v", err)
+		}
+	}
+
+	if len(request.Save) != 0 {
+		if _, _, err := broadlinkrm.SplitCodeName(request.Save); err != nil {
+			return nil, badRequest("%v", err)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if _, err := s.command(3, nil); err != nil {
+		return nil, err
+	}
+
+	s.learning, s.learnSave = true, request.Save
+	return learnRecord{Device: deviceMAC(s.device), Status: "learning", Name: request.Save}, nil
+}
+
+func (srv *server) pollLearning(r *http.Request) (interface{}, error) {
+	s, err := srv.session(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	record := learnRecord{Device: deviceMAC(s.device), Status: "idle"}
+	if !s.learning {
+		return record, nil
+	}
+
+	record.Status = "learning"
+	learnedCode := broadlinkrm.Command(4, nil, &s.device)
+	if len(learnedCode) == 0 {
+		return record, nil
+	}
+
+	record.Status, record.Code = "learned", hex.EncodeToString(learnedCode)
+	if len(s.learnSave) != 0 {
+		if err := srv.saveLearnedCode(s.device, s.learnSave, learnedCode); err != nil {
+			return nil, err
+		}
+
+		record.Name = s.learnSave
+	}
+
+	s.learning, s.learnSave = false, ""
+	return record, nil
+}
+
+// saveLearnedCode stores a code learned with device in the code library
+func (srv *server) saveLearnedCode(device broadlinkrm.Device, name string, learnedCode []byte) error {
+	code := broadlinkrm.NewBroadlinkCode(learnedCode)
+	code.LearnedFrom = deviceMAC(device)
+	if alias := cfg.aliasOf(code.LearnedFrom); len(alias) != 0 {
+		code.LearnedFrom = alias
+	}
+	code.Learned = time.Now().UTC()
+
+	srv.libraryLock.Lock()
+	defer srv.libraryLock.Unlock()
+	return updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		return lib.Set(name, code)
+	})
+}
+
+func (srv *server) sensors(r *http.Request) (interface{}, error) {
+	s, err := srv.session(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	sensors, err := broadlinkrm.ReadSensors(&s.device)
+	if err != nil {
+		return nil, err
+	}
+
+	record := sensorsRecord{Device: deviceMAC(s.device), Temperature: sensors.Temperature}
+	if sensors.HasHumidity {
+		record.Humidity = &sensors.Humidity
+	}
+
+	return record, nil
+}
+
+func (srv *server) loadLibrary() (*broadlinkrm.CodeLibrary, error) {
+	srv.libraryLock.Lock()
+	defer srv.libraryLock.Unlock()
+	return loadLibrary()
+}
+
+func (srv *server) listCodes(r *http.Request) (interface{}, error) {
+	lib, err := srv.loadLibrary()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []libraryCodeRecord{}
+	for _, name := range lib.Names() {
+		code, _ := lib.Get(name)
+		records = append(records, libraryCodeRecord{Name: name, Code: *code})
+	}
+
+	return records, nil
+}
+
+func (srv *server) getCode(r *http.Request) (interface{}, error) {
+	lib, err := srv.loadLibrary()
+	if err != nil {
+		return nil, err
+	}
+
+	name := codeNameOf(r)
+	code, err := lib.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return libraryCodeRecord{Name: name, Code: *code}, nil
+}
+
+func (srv *server) putCode(r *http.Request) (interface{}, error) {
+	// without repeat and frequency the ones of the code data are stored
+	var request struct {
+		broadlinkrm.Code
+		Repeat *uint8 `json:"repeat"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, badRequest("invalid code: %v", err)
+	}
+
+	if len(request.Format) == 0 {
+		request.Format = broadlinkrm.FormatBroadlink
+	}
+
+	data, err := hex.DecodeString(strings.Replace(request.Data, " ", "", -1))
+	if err != nil {
+		return nil, badRequest("invalid code: %v", err)
+	}
+
+	code, err := broadlinkrm.NewCode(request.Format, data)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	code.LearnedFrom, code.Learned = request.LearnedFrom, request.Learned
+	if request.Frequency != 0 {
+		code.Frequency = request.Frequency
+	}
+	if request.Repeat != nil {
+		code.Repeat = *request.Repeat
+	}
+
+	name := codeNameOf(r)
+	srv.libraryLock.Lock()
+	defer srv.libraryLock.Unlock()
+	err = updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		return lib.Set(name, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return libraryCodeRecord{Name: name, Code: code}, nil
+}
+
+func (srv *server) deleteCode(r *http.Request) (interface{}, error) {
+	name := codeNameOf(r)
+	srv.libraryLock.Lock()
+	defer srv.libraryLock.Unlock()
+	return nil, updateLibrary(func(lib *broadlinkrm.CodeLibrary) error {
+		return lib.Delete(name)
+	})
+}
+
+func (srv *server) runMacro(r *http.Request) (interface{}, error) {
+	macros, err := loadMacros()
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := srv.loadLibrary()
+	if err != nil {
+		return nil, err
+	}
+
+	runner := broadlinkrm.MacroRunner{
+		Macros: macros,
+		Codes:  codes,
+		Send: func(name string, packet []byte) error {
+			if len(name) == 0 {
+				return badRequest("macro step without device")
+			}
+
+			s, err := srv.session(name)
+			if err != nil {
+				return err
+			}
+
+			s.Lock()
+			defer s.Unlock()
+			return s.send(packet)
+		},
+	}
+
+	results, err := runner.Run(r.PathValue("name"))
+	records := []stepRecord{}
+	for _, result := range results {
+		record := stepRecord{Macro: result.Macro, Step: result.Step, Action: result.Action, Code: result.Code, Device: result.Device, Duration: result.Duration.Seconds()}
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+		}
+		records = append(records, record)
+	}
+
+	// the steps run until the failing one are answered with the error
+	if err != nil && len(records) == 0 {
+		return nil, err
+	}
+
+	return records, err
+}
+
+func codeNameOf(r *http.Request) string {
+	return r.PathValue("remote") + "/" + r.PathValue("button")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+func TestHandleAnswersResultWithError(t *testing.T) {
+	srv := &server{}
+	steps := []stepRecord{{Macro: "movie", Step: 1, Action: "send"}}
+	handler := srv.handle(func(r *http.Request) (interface{}, error) {
+		return steps, errors.New("step 2 failed")
+	})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/macros/movie/run", nil))
+
+	var answer struct {
+		Error  string       `json:"error"`
+		Result []stepRecord `json:"result"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusInternalServerError || answer.Error != "step 2 failed" || len(answer.Result) != 1 {
+		t.Errorf("got status %d and answer %+v", recorder.Code, answer)
+	}
+}
+
+func TestHandleAnswersError(t *testing.T) {
+	srv := &server{}
+	handler := srv.handle(func(r *http.Request) (interface{}, error) {
+		return nil, badRequest("no code")
+	})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/devices/tv/send", nil))
+
+	var answer map[string]interface{}
+	if err := json.NewDecoder(recorder.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := answer["result"]; recorder.Code != http.StatusBadRequest || found {
+		t.Errorf("got status %d and answer %v", recorder.Code, answer)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: tv/mute", broadlinkrm.ErrCodeNotFound), http.StatusNotFound},
+		{fmt.Errorf("%w: movie", broadlinkrm.ErrMacroNotFound), http.StatusNotFound},
+		{broadlinkrm.ErrNoResponse, http.StatusGatewayTimeout},
+		{broadlinkrm.ErrCommandFailed, http.StatusBadGateway},
+		{badRequest("no code"), http.StatusBadRequest},
+		{statusError{http.StatusNotFound, errors.New("unknown device")}, http.StatusNotFound},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if status := errorStatus(test.err); status != test.want {
+			t.Errorf("%v: got status %d, want %d", test.err, status, test.want)
+		}
+	}
+}
+
+func TestServeRoutes(t *testing.T) {
+	// the device never answers
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	timeout := broadlinkrm.DefaultTimeout
+	broadlinkrm.DefaultTimeout = 1
+	defer func() { broadlinkrm.DefaultTimeout = timeout }()
+
+	lib := broadlinkrm.NewCodeLibrary()
+	packet, _ := broadlinkrm.EncodeIR(broadlinkrm.IRCode{Protocol: "NEC", Address: 0x04, Command: 0x08})
+	lib.Set("tv/power", broadlinkrm.NewBroadlinkCode(packet))
+	path := filepath.Join(t.TempDir(), "codes.yaml")
+	if err := lib.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Libraries = []string{path}
+	cfg.Macros = []string{filepath.Join(t.TempDir(), "macros.yaml")}
+	cfg.Devices = map[string]configDevice{"tv": {MAC: "00:00:00:00:00:00"}}
+
+	device := broadlinkrm.Device{DeviceType: 0x2737, DeviceAddr: conn.LocalAddr().(*net.UDPAddr)}
+	srv := &server{sessions: map[string]*session{"00:00:00:00:00:00": {device: device}}, metrics: newMetrics()}
+	handler := srv.routes()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"GET", "/devices", "", http.StatusOK},
+		{"GET", "/devices/000000000000", "", http.StatusOK},
+		{"GET", "/devices/tv", "", http.StatusOK},
+		{"GET", "/devices/living", "", http.StatusNotFound},
+		{"GET", "/devices/112233445566", "", http.StatusNotFound},
+		{"POST", "/devices/112233445566/send", `{"name":"tv/power"}`, http.StatusNotFound},
+		{"POST", "/devices/tv/send", `{"code":"zz"}`, http.StatusBadRequest},
+		{"POST", "/devices/tv/send", `{"code":"26"}`, http.StatusBadRequest},
+		{"POST", "/devices/tv/send", `{"code":"0000 006d 0002 0000","format":"pronto"}`, http.StatusBadRequest},
+		{"POST", "/devices/tv/send", `{`, http.StatusBadRequest},
+		{"POST", "/devices/tv/send", `{}`, http.StatusBadRequest},
+		{"POST", "/devices/tv/send", `{"name":"tv/mute"}`, http.StatusNotFound},
+		{"GET", "/codes", "", http.StatusOK},
+		{"GET", "/codes/tv/power", "", http.StatusOK},
+		{"GET", "/codes/tv/mute", "", http.StatusNotFound},
+		{"PUT", "/codes/tv/mute", `{"data":"zz"}`, http.StatusBadRequest},
+		{"PUT", "/codes/tv/mute", `{"format":"morse","data":"2600"}`, http.StatusBadRequest},
+		{"PUT", "/codes/tv/mute", fmt.Sprintf(`{"data":"%x","repeat":2}`, packet), http.StatusOK},
+		{"GET", "/codes/tv/mute", "", http.StatusOK},
+		{"DELETE", "/codes/tv/mute", "", http.StatusNoContent},
+		{"DELETE", "/codes/tv/mute", "", http.StatusNotFound},
+		{"POST", "/macros/movie/run", "", http.StatusNotFound},
+		{"GET", "/metrics", "", http.StatusOK},
+		// neither the command nor the renewed authentication are answered
+		{"POST", "/devices/tv/send", `{"name":"tv/power"}`, http.StatusGatewayTimeout},
+	}
+
+	for _, test := range tests {
+		start := time.Now()
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if recorder.Code != test.want {
+			t.Errorf("%v %v %v: got status %d (%v), want %d", test.method, test.path, test.body, recorder.Code, strings.TrimSpace(recorder.Body.String()), test.want)
+		}
+		if elapsed := time.Since(start); test.want != http.StatusGatewayTimeout && elapsed > time.Second/2 {
+			t.Errorf("%v %v: answered after %v", test.method, test.path, elapsed)
+		}
+	}
+
+	// the code added by PUT was removed by DELETE, the other codes are kept
+	codes, err := broadlinkrm.LoadCodeLibrary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := codes.Names(); len(names) != 1 || names[0] != "tv/power" {
+		t.Errorf("got codes %v", names)
+	}
+}
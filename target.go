@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+// selectorFlags holds the options to filter the discovered devices
+type selectorFlags struct {
+	device     *string
+	ip         *string
+	mac        *string
+	name       *string
+	deviceType *string
+
+	discoveryTimeout *uint
+}
+
+// selector describes the devices a command acts on, empty fields match every device
+type selector struct {
+	ip         net.IP
+	mac        net.HardwareAddr
+	name       string
+	deviceType string
+
+	// discoveryTimeout in seconds to wait for answers of a broadcast discovery
+	discoveryTimeout uint
+}
+
+// targetFlags holds the options to select the one device a command acts on
+type targetFlags struct {
+	selectorFlags
+	auth *bool
+}
+
+func addSelectorFlags(fs *flag.FlagSet) (flags selectorFlags) {
+	flags.device = new(string)
+	fs.StringVar(flags.device, "device", "", "alias from the config file, ip, mac or name of the device")
+	fs.StringVar(flags.device, "d", "", "shorthand for -device")
+	flags.ip = fs.String("ip", "", "ip of the device - if omitted a broadcast is send")
+	flags.mac = fs.String("mac", "", "mac of the device")
+	flags.name = fs.String("name", "", "name of the device as set in the Broadlink app")
+	flags.deviceType = fs.String("type", "", "device type (e.g. 0x27c2) or model (e.g. \"RM mini 3\")")
+	flags.discoveryTimeout = fs.Uint("discoverytimeout", cfg.DiscoveryTimeout, "seconds to wait for answers of a broadcast discovery")
+	return
+}
+
+func addTargetFlags(fs *flag.FlagSet) (target targetFlags) {
+	target.selectorFlags = addSelectorFlags(fs)
+	target.auth = fs.Bool("a", cfg.authDefault(), "authenticate against device")
+	return
+}
+
+// selector builds the device selector from the flags, -device is resolved against the aliases of the config file
+func (flags selectorFlags) selector() (sel selector, err error) {
+	if len(*flags.device) != 0 {
+		if sel, err = parseDeviceArgument(*flags.device); err != nil {
+			return
+		}
+	}
+
+	if len(*flags.ip) != 0 {
+		if sel.ip = net.ParseIP(*flags.ip); sel.ip == nil {
+			return sel, fmt.Errorf("invalid ip %q", *flags.ip)
+		}
+	}
+
+	if len(*flags.mac) != 0 {
+		if sel.mac, err = parseMAC(*flags.mac); err != nil {
+			return
+		}
+	}
+
+	if len(*flags.name) != 0 {
+		sel.name = *flags.name
+	}
+
+	if len(*flags.deviceType) != 0 {
+		sel.deviceType = *flags.deviceType
+	}
+
+	sel.discoveryTimeout = *flags.discoveryTimeout
+	return
+}
+
+// parseDeviceArgument interprets value as alias, ip, mac or name of a device - in this order
+func parseDeviceArgument(value string) (sel selector, err error) {
+	if device, found := cfg.Devices[value]; found {
+		if len(device.IP) != 0 {
+			if sel.ip = net.ParseIP(device.IP); sel.ip == nil {
+				return sel, fmt.Errorf("invalid ip %q for device %q in config file", device.IP, value)
+			}
+		}
+
+		if len(device.MAC) != 0 {
+			if sel.mac, err = parseMAC(device.MAC); err != nil {
+				return sel, fmt.Errorf("device %q in config file: %v", value, err)
+			}
+		}
+
+		sel.deviceType = device.Type
+		return
+	}
+
+	if sel.ip = net.ParseIP(value); sel.ip != nil {
+		return
+	}
+
+	if mac, err := parseMAC(value); err == nil {
+		sel.mac = mac
+		return sel, nil
+	}
+
+	sel.name = value
+	return
+}
+
+// parseMAC parses a mac with or without separators
+func parseMAC(value string) (net.HardwareAddr, error) {
+	if mac, err := hex.DecodeString(value); err == nil && len(mac) == 6 {
+		return mac, nil
+	}
+
+	mac, err := net.ParseMAC(value)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("invalid mac %q", value)
+	}
+
+	return mac, nil
+}
+
+func (sel selector) matches(device broadlinkrm.Device) bool {
+	if sel.ip != nil && !sel.ip.Equal(device.DeviceAddr.IP) {
+		return false
+	}
+
+	if sel.mac != nil && !bytes.Equal(sel.mac, device.DeviceMac()) {
+		return false
+	}
+
+	if len(sel.name) != 0 && !strings.EqualFold(sel.name, device.DeviceName) {
+		return false
+	}
+
+	if len(sel.deviceType) != 0 {
+		deviceType, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(sel.deviceType), "0x"), 16, 16)
+		if err == nil {
+			return uint16(deviceType) == device.DeviceType
+		}
+
+		return strings.EqualFold(sel.deviceType, device.Model())
+	}
+
+	return true
+}
+
+func (sel selector) String() string {
+	var parts []string
+	if sel.ip != nil {
+		parts = append(parts, "ip "+sel.ip.String())
+	}
+	if sel.mac != nil {
+		parts = append(parts, "mac "+sel.mac.String())
+	}
+	if len(sel.name) != 0 {
+		parts = append(parts, fmt.Sprintf("name %q", sel.name))
+	}
+	if len(sel.deviceType) != 0 {
+		parts = append(parts, fmt.Sprintf("type %q", sel.deviceType))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// resolve discovers the device selected by the target flags and authenticates against it if requested.
+// Exactly one device must match, otherwise the exit code to use is returned.
+func (target targetFlags) resolve() (broadlinkrm.Device, int) {
+	sel, err := target.selector()
+	if err != nil {
+		return broadlinkrm.Device{}, fail(exitUsage, "%v", err)
+	}
+
+	dev, exitCode, err := selectDevice(sel, *target.auth)
+	if err != nil {
+		return dev, fail(exitCode, "%v", err)
+	}
+
+	return dev, exitOK
+}
+
+// selectDevice discovers the one device matching sel and authenticates against it if requested.
+// On error the exit code to use is returned too.
+// Without ip a discovery timeout is required, with 0 only the first answer would be seen and further matching devices missed.
+func selectDevice(sel selector, auth bool) (broadlinkrm.Device, int, error) {
+	if sel.ip == nil && sel.discoveryTimeout == 0 {
+		return broadlinkrm.Device{}, exitUsage, errors.New("-discoverytimeout must be at least 1 second to select a device without ip")
+	}
+
+	devices := discover(sel)
+
+	switch len(devices) {
+	case 0:
+		if desc := sel.String(); len(desc) != 0 {
+			return broadlinkrm.Device{}, exitNoDevice, fmt.Errorf("no device found matching %v", desc)
+		}
+		return broadlinkrm.Device{}, exitNoDevice, errors.New("no device found")
+	case 1:
+	default:
+		var macs []string
+		for _, device := range devices {
+			macs = append(macs, deviceMAC(device))
+		}
+		return broadlinkrm.Device{}, exitNoDevice, fmt.Errorf("%d devices found (%v) - select one with -device, -mac, -name or -type", len(devices), strings.Join(macs, ", "))
+	}
+
+	dev := devices[0]
+	if auth {
+		if err := broadlinkrm.Auth(&dev); err != nil {
+			return dev, exitDevice, fmt.Errorf("authentication failed: %v", err)
+		}
+		printMessage(2, "Device authenticated\n")
+	}
+
+	return dev, exitOK, nil
+}
+
+// discover searches for the devices matching sel, if sel has an ip only this device is asked
+func discover(sel selector) (devices []broadlinkrm.Device) {
+	var found chan broadlinkrm.Device
+	if sel.ip != nil {
+		found = broadlinkrm.Hello(0, sel.ip)
+	} else {
+		found = broadlinkrm.Hello(time.Duration(sel.discoveryTimeout), nil)
+	}
+
+	for device := range found {
+		if sel.matches(device) {
+			devices = append(devices, device)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/waringer/broadlink/broadlinkrm"
+)
+
+func TestSelectDevice(t *testing.T) {
+	timeout := broadlinkrm.DefaultTimeout
+	broadlinkrm.DefaultTimeout = 1
+	defer func() { broadlinkrm.DefaultTimeout = timeout }()
+
+	// no device in the test network has this mac, nothing answers Hello on localhost
+	mac := net.HardwareAddr{0x34, 0xea, 0x34, 0x01, 0x02, 0x03}
+	tests := []struct {
+		sel     selector
+		want    int
+		wantErr string
+	}{
+		{selector{mac: mac}, exitUsage, "-discoverytimeout must be at least 1 second"},
+		{selector{mac: mac, discoveryTimeout: 1}, exitNoDevice, "no device found matching mac 34:ea:34:01:02:03"},
+		{selector{ip: net.IPv4(127, 0, 0, 1)}, exitNoDevice, "no device found matching ip 127.0.0.1"},
+	}
+
+	for _, test := range tests {
+		_, code, err := selectDevice(test.sel, true)
+		if code != test.want || err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+			t.Errorf("%v: got exit code %d (%v), want %d (%v)", test.sel, code, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseDeviceArgument(t *testing.T) {
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.Devices = map[string]configDevice{
+		"tv":     {MAC: "34:ea:34:01:02:03", Type: "0x2712"},
+		"avr":    {IP: "192.168.1.21"},
+		"badip":  {IP: "192.168.1"},
+		"badmac": {MAC: "34:ea:34"},
+	}
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"tv", `mac 34:ea:34:01:02:03, type "0x2712"`, false},
+		{"avr", "ip 192.168.1.21", false},
+		{"badip", "", true},
+		{"badmac", "", true},
+		{"192.168.1.20", "ip 192.168.1.20", false},
+		{"fe80::1", "ip fe80::1", false},
+		{"34ea34010203", "mac 34:ea:34:01:02:03", false},
+		{"34-ea-34-01-02-03", "mac 34:ea:34:01:02:03", false},
+		{"Living Room", `name "Living Room"`, false},
+		// neither alias, ip nor mac
+		{"34ea340102", `name "34ea340102"`, false},
+	}
+
+	for _, test := range tests {
+		sel, err := parseDeviceArgument(test.value)
+		if (err != nil) != test.wantErr || err == nil && sel.String() != test.want {
+			t.Errorf("%v: got %v (%v), want %v", test.value, sel, err, test.want)
+		}
+	}
+}
+
+func TestParseMAC(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"34ea34010203", "34:ea:34:01:02:03"},
+		{"34EA34010203", "34:ea:34:01:02:03"},
+		{"34:ea:34:01:02:03", "34:ea:34:01:02:03"},
+		{"34-EA-34-01-02-03", "34:ea:34:01:02:03"},
+		{"34ea.3401.0203", "34:ea:34:01:02:03"},
+		{"34ea340102", ""},
+		{"34ea3401020304", ""},
+		{"02:00:5e:10:00:00:00:01", ""},
+		{"34:ea:34:01:02:zz", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		mac, err := parseMAC(test.value)
+		if len(test.want) == 0 {
+			if err == nil {
+				t.Errorf("%q: got %v, want an error", test.value, mac)
+			}
+		} else if err != nil || mac.String() != test.want {
+			t.Errorf("%q: got %v (%v), want %v", test.value, mac, err, test.want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	device := broadlinkrm.Device{DeviceType: 0x2712, DeviceName: "Living Room", DeviceAddr: &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20)}}
+
+	tests := []struct {
+		sel  selector
+		want bool
+	}{
+		{selector{}, true},
+		{selector{ip: net.IPv4(192, 168, 1, 20)}, true},
+		{selector{ip: net.IPv4(192, 168, 1, 21)}, false},
+		{selector{name: "living room"}, true},
+		{selector{deviceType: "0x2712"}, true},
+		{selector{deviceType: "2737"}, false},
+		{selector{name: "Kitchen"}, false},
+		{selector{deviceType: "rm PRO/pro+"}, true},
+		{selector{deviceType: "RM mini 3"}, false},
+		{selector{ip: net.IPv4(192, 168, 1, 20), name: "Living Room", deviceType: "0x2712"}, true},
+		{selector{ip: net.IPv4(192, 168, 1, 20), name: "Kitchen"}, false},
+		// the mac of the device is all zeros
+		{selector{mac: net.HardwareAddr{0, 0, 0, 0, 0, 0}}, true},
+		{selector{mac: net.HardwareAddr{1, 2, 3, 4, 5, 6}}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.sel.matches(device); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.sel, got, test.want)
+		}
+	}
+}